@@ -0,0 +1,180 @@
+package otchkiss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/ryo-yamaoka/otchkiss/threshold"
+)
+
+// abortInterval defines how often the abort conditions are checked during the measurement.
+const abortInterval = 250 * time.Millisecond
+
+// AbortCondition defines when the test is aborted without waiting for the end of the measurement.
+type AbortCondition struct {
+	// Threshold is the condition which must hold during the measurement, the test is aborted when it fails.
+	Threshold threshold.Threshold
+
+	// Window defines how long the latest requests which Threshold is evaluated against last.
+	// 0 means all the requests measured so far.
+	Window time.Duration
+
+	// MinRequests defines how many requests are needed before Threshold is evaluated, so that a few early failures do not abort the test.
+	MinRequests int
+}
+
+func (c AbortCondition) String() string {
+	if c.Window == 0 {
+		return c.Threshold.String()
+	}
+	return fmt.Sprintf("%s over %s", c.Threshold, c.Window)
+}
+
+func (c AbortCondition) validate() error {
+	if err := c.Threshold.Validate(); err != nil {
+		return err
+	}
+	if !(c.Window >= 0) {
+		return errors.New("window must be >= 0 sec")
+	}
+	if !(c.MinRequests >= 0) {
+		return errors.New("min requests must be >= 0")
+	}
+	return nil
+}
+
+// ErrAborted is returned by Start when one of the AbortConditions fails.
+type ErrAborted struct {
+	Condition AbortCondition
+
+	// Observed is the value of the metric when the test was aborted.
+	Observed float64
+}
+
+func (e *ErrAborted) Error() string {
+	return fmt.Sprintf("aborted: %s is not satisfied (observed: %s)", e.Condition, e.Condition.Threshold.Metric.Format(e.Observed))
+}
+
+// abortMonitor keeps the latest requests of each abort condition and checks the conditions periodically.
+type abortMonitor struct {
+	conditions []AbortCondition
+	all        *result.Result
+
+	// recent holds the requests within the window of each condition, it is nil for the conditions without the window.
+	recent []*result.Rolling
+
+	mu      sync.Mutex
+	begin   time.Time
+	aborted *ErrAborted
+}
+
+func newAbortMonitor(conditions []AbortCondition, all *result.Result) *abortMonitor {
+	recent := make([]*result.Rolling, len(conditions))
+	for i, c := range conditions {
+		if c.Window != 0 {
+			recent[i], _ = result.NewRolling(c.Window) // The window has been validated.
+		}
+	}
+	return &abortMonitor{
+		conditions: conditions,
+		all:        all,
+		recent:     recent,
+	}
+}
+
+// add records the measured sample for the conditions with the window.
+func (m *abortMonitor) add(s sample) {
+	m.mu.Lock()
+	if m.begin.IsZero() {
+		m.begin = s.completed
+	}
+	m.mu.Unlock()
+
+	for i, c := range m.conditions {
+		if m.recent[i] == nil {
+			continue
+		}
+		if tag := c.Threshold.Tag; tag != nil && !slices.Contains(s.tags, *tag) {
+			continue
+		}
+		m.recent[i].Append(s.completed, s.elapsed.Seconds(), s.err != nil)
+	}
+}
+
+// run checks the conditions until ctx is done, and cancels ctx when one of them fails.
+func (m *abortMonitor) run(ctx context.Context, cancel context.CancelFunc) {
+	ticker := time.NewTicker(abortInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.check(time.Now()); err != nil {
+				m.mu.Lock()
+				m.aborted = err
+				m.mu.Unlock()
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// err returns why the test was aborted, or nil if it was not.
+func (m *abortMonitor) err() *ErrAborted {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.aborted
+}
+
+// check evaluates the conditions at now, and returns the first failed one.
+// A condition whose metric cannot be observed yet, such as no request with the tag, does not fail.
+func (m *abortMonitor) check(now time.Time) *ErrAborted {
+	for i, c := range m.conditions {
+		r, elapsed, th := m.all, m.all.Elapsed(), c.Threshold
+		if c.Window != 0 {
+			r, elapsed = m.windowResult(i, now)
+			th.Tag = nil // The samples have already been filtered by the tag.
+		}
+		if count(r, th.Tag) < int64(max(c.MinRequests, 1)) {
+			continue
+		}
+
+		v := threshold.Evaluate(r, elapsed, []threshold.Threshold{th})
+		if o := v.Outcomes[0]; o.Err == nil && !o.Passed {
+			return &ErrAborted{Condition: c, Observed: o.Observed}
+		}
+	}
+	return nil
+}
+
+// windowResult returns the Result of the requests within the window of the i-th condition.
+// elapsed is the time since the beginning of the kept requests, which is about the window, or shorter if the measurement is.
+func (m *abortMonitor) windowResult(i int, now time.Time) (r *result.Result, elapsed time.Duration) {
+	m.mu.Lock()
+	begin := m.begin
+	m.mu.Unlock()
+
+	r, since := m.recent[i].Result(now)
+	if since.Before(begin) {
+		since = begin
+	}
+	return r, now.Sub(since)
+}
+
+// count returns the number of the requests in r, or the ones with tag if it is not nil.
+func count(r *result.Result, tag *result.Tag) int64 {
+	if tag != nil {
+		if !slices.Contains(r.Tags(), *tag) {
+			return 0
+		}
+		r = r.Tagged(*tag)
+	}
+	return r.Succeeded() + r.Failed()
+}
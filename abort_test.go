@@ -0,0 +1,114 @@
+package otchkiss
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/ryo-yamaoka/otchkiss/setting"
+	"github.com/ryo-yamaoka/otchkiss/threshold"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// degradingRequesterImpl starts failing after the given number of requests.
+type degradingRequesterImpl struct {
+	countingRequesterImpl
+	healthy  int64
+	requests atomic.Int64
+}
+
+func (dr *degradingRequesterImpl) RequestOne(_ context.Context) error {
+	time.Sleep(1 * time.Millisecond)
+	if dr.requests.Add(1) > dr.healthy {
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func TestStartAbort(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]AbortCondition{
+		"rolling window": {
+			Threshold:   threshold.Threshold{Metric: threshold.ErrorRate, Op: threshold.Less, Value: 50},
+			Window:      300 * time.Millisecond,
+			MinRequests: 10,
+		},
+		"whole measurement": {
+			Threshold:   threshold.Threshold{Metric: threshold.ErrorRate, Op: threshold.Less, Value: 50},
+			MinRequests: 10,
+		},
+	}
+
+	for tn, c := range testCases {
+		c := c
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			dr := &degradingRequesterImpl{healthy: 50}
+			ot, err := FromConfig(dr, &setting.Setting{MaxConcurrent: 1, RunDuration: 10 * time.Second}, 100)
+			require.NoError(t, err)
+			ot.AbortConditions = []AbortCondition{c}
+
+			begin := time.Now()
+			err = ot.Start(context.Background())
+			assert.Less(t, time.Since(begin), 5*time.Second, "must not wait for the run duration")
+
+			var aborted *ErrAborted
+			require.ErrorAs(t, err, &aborted)
+			assert.Equal(t, c, aborted.Condition)
+			assert.GreaterOrEqual(t, aborted.Observed, 50.0)
+			assert.Equal(t, 1, dr.terminates)
+		})
+	}
+}
+
+func TestStartNotAborted(t *testing.T) {
+	t.Parallel()
+
+	ot, err := FromConfig(&degradingRequesterImpl{healthy: 1 << 30}, &setting.Setting{MaxConcurrent: 1, RunDuration: 500 * time.Millisecond}, 100)
+	require.NoError(t, err)
+	search := result.Tag{Key: "endpoint", Value: "search"}
+	ot.AbortConditions = []AbortCondition{
+		{Threshold: threshold.Threshold{Metric: threshold.ErrorRate, Op: threshold.Less, Value: 1}, Window: 100 * time.Millisecond},
+		{Threshold: threshold.Threshold{Metric: threshold.Percentile(95), Op: threshold.Less, Value: 1}},
+		{Threshold: threshold.Threshold{Metric: threshold.ErrorRate, Op: threshold.Less, Value: 1, Tag: &search}, Window: 100 * time.Millisecond},
+	}
+	assert.NoError(t, ot.Start(context.Background()))
+}
+
+func TestStartInvalidAbortCondition(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]AbortCondition{
+		"threshold":    {Threshold: threshold.Threshold{Metric: "unknown", Op: threshold.Less}},
+		"window":       {Threshold: threshold.Threshold{Metric: threshold.RPS, Op: threshold.Less}, Window: -1},
+		"min requests": {Threshold: threshold.Threshold{Metric: threshold.RPS, Op: threshold.Less}, MinRequests: -1},
+	}
+
+	for tn, c := range testCases {
+		c := c
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			ot, err := FromConfig(&testRequesterImpl{}, &setting.Setting{RunDuration: 1 * time.Second}, 0)
+			require.NoError(t, err)
+			ot.AbortConditions = []AbortCondition{c}
+			assert.Error(t, ot.Start(context.Background()))
+		})
+	}
+}
+
+func TestAbortedError(t *testing.T) {
+	t.Parallel()
+
+	err := &ErrAborted{
+		Condition: AbortCondition{Threshold: threshold.Threshold{Metric: threshold.Percentile(95), Op: threshold.Less, Value: 0.2}, Window: 10 * time.Second},
+		Observed:  0.35,
+	}
+	assert.Equal(t, "aborted: p95 < 200ms over 10s is not satisfied (observed: 350ms)", err.Error())
+}
@@ -0,0 +1,132 @@
+package arrival
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Process defines the intervals between the requests which Otchkiss dispatches.
+// All implemented processes are thread safe.
+type Process interface {
+
+	// Next returns the interval until the next request when the target is rps requests per second.
+	// rps <= 0 means unlimited, and processes which follow the rate return 0.
+	// ok is false when no more request should be dispatched.
+	Next(rps float64) (d time.Duration, ok bool)
+}
+
+// Constant spaces requests evenly.
+type Constant struct{}
+
+// Next returns 1/rps seconds.
+func (Constant) Next(rps float64) (time.Duration, bool) {
+	return interval(rps), true
+}
+
+// Poisson spaces requests by exponentially distributed intervals, so requests arrive as a Poisson process.
+type Poisson struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewPoisson returns Poisson process with the given random seed.
+func NewPoisson(seed int64) *Poisson {
+	return &Poisson{
+		rnd: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Next returns an exponentially distributed interval whose mean is 1/rps seconds.
+func (p *Poisson) Next(rps float64) (time.Duration, bool) {
+	if rps <= 0 {
+		return 0, true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return time.Duration(p.rnd.ExpFloat64() * float64(interval(rps))), true
+}
+
+// UniformJitter spaces requests by 1/rps seconds randomly shifted in proportion to jitter.
+type UniformJitter struct {
+	jitter float64
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewUniformJitter returns UniformJitter process with the given random seed.
+// jitter must be between 0 and 1, for example 0.2 makes intervals uniformly distributed between 80% and 120% of 1/rps seconds.
+func NewUniformJitter(jitter float64, seed int64) (*UniformJitter, error) {
+	if jitter < 0 || jitter > 1 {
+		return nil, errors.New("jitter must be between 0 and 1")
+	}
+
+	return &UniformJitter{
+		jitter: jitter,
+		rnd:    rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+// Next returns an uniformly distributed interval whose mean is 1/rps seconds.
+func (u *UniformJitter) Next(rps float64) (time.Duration, bool) {
+	if rps <= 0 {
+		return 0, true
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	f := 1 + u.jitter*(2*u.rnd.Float64()-1)
+	return time.Duration(f * float64(interval(rps))), true
+}
+
+// Replay spaces requests as the recorded timestamps, for example taken from an access log.
+// The target rate is ignored, and no more request is dispatched after the last timestamp.
+type Replay struct {
+	mu         sync.Mutex
+	timestamps []time.Time
+	pos        int
+}
+
+// NewReplay returns Replay process which follows the given timestamps.
+// The timestamps must be sorted in ascending order.
+func NewReplay(timestamps []time.Time) (*Replay, error) {
+	if len(timestamps) == 0 {
+		return nil, errors.New("no timestamps")
+	}
+	for i := 1; i < len(timestamps); i++ {
+		if timestamps[i].Before(timestamps[i-1]) {
+			return nil, errors.New("timestamps must be sorted in ascending order")
+		}
+	}
+
+	return &Replay{
+		timestamps: timestamps,
+	}, nil
+}
+
+// Next returns the interval from the timestamp of the request being dispatched to the following one.
+// The last timestamp returns 0, and no more request is dispatched after it.
+func (r *Replay) Next(_ float64) (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pos >= len(r.timestamps) {
+		return 0, false
+	}
+	var d time.Duration
+	if r.pos+1 < len(r.timestamps) {
+		d = r.timestamps[r.pos+1].Sub(r.timestamps[r.pos])
+	}
+	r.pos++
+	return d, true
+}
+
+func interval(rps float64) time.Duration {
+	if rps <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / rps)
+}
@@ -0,0 +1,102 @@
+package arrival
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstant(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		rps  float64
+		want time.Duration
+	}{
+		"10 RPS": {
+			rps:  10,
+			want: 100 * time.Millisecond,
+		},
+		"0.5 RPS": {
+			rps:  0.5,
+			want: 2 * time.Second,
+		},
+		"unlimited": {
+			rps:  0,
+			want: 0,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			d, ok := Constant{}.Next(tc.rps)
+			assert.True(t, ok)
+			assert.Equal(t, tc.want, d)
+		})
+	}
+}
+
+func TestPoisson(t *testing.T) {
+	t.Parallel()
+
+	const n = 10000
+	p := NewPoisson(1)
+	var sum time.Duration
+	for i := 0; i < n; i++ {
+		d, ok := p.Next(100)
+		require.True(t, ok)
+		require.GreaterOrEqual(t, d, time.Duration(0))
+		sum += d
+	}
+	assert.InDelta(t, float64(10*time.Millisecond), float64(sum/n), float64(500*time.Microsecond))
+
+	d, ok := p.Next(0)
+	assert.True(t, ok)
+	assert.Zero(t, d)
+}
+
+func TestUniformJitter(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewUniformJitter(-0.1, 1)
+	assert.Error(t, err)
+	_, err = NewUniformJitter(1.1, 1)
+	assert.Error(t, err)
+
+	u, err := NewUniformJitter(0.2, 1)
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		d, ok := u.Next(10)
+		require.True(t, ok)
+		require.GreaterOrEqual(t, d, 80*time.Millisecond)
+		require.LessOrEqual(t, d, 120*time.Millisecond)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewReplay(nil)
+	assert.Error(t, err)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err = NewReplay([]time.Time{base.Add(time.Second), base})
+	assert.Error(t, err)
+
+	r, err := NewReplay([]time.Time{base, base.Add(100 * time.Millisecond), base.Add(100 * time.Millisecond), base.Add(1 * time.Second)})
+	require.NoError(t, err)
+	var got []time.Duration
+	for {
+		d, ok := r.Next(1000)
+		if !ok {
+			break
+		}
+		got = append(got, d)
+	}
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 0, 900 * time.Millisecond, 0}, got)
+}
@@ -0,0 +1,89 @@
+package otchkiss
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+var chartBlocks = []string{"▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"}
+
+// chart renders values as a bar chart of the given height and width in characters, like below.
+// When there are more values than width, adjacent values are merged by aggregate.
+//
+//	10.0 ┤    ▄██
+//	     │ ▂▆████
+//	 0.0 ┤███████
+//	     0s     7s
+func chart(values []float64, height, width int, span time.Duration, aggregate func([]float64) float64, format func(float64) string) string {
+	if len(values) == 0 || height <= 0 || width <= 0 {
+		return ""
+	}
+
+	cols := values
+	if len(values) > width {
+		cols = make([]float64, width)
+		for i := range cols {
+			from, to := i*len(values)/width, (i+1)*len(values)/width
+			cols[i] = aggregate(values[from:to])
+		}
+	}
+
+	var top float64
+	for _, v := range cols {
+		top = math.Max(top, v)
+	}
+
+	topLabel, bottomLabel := format(top), format(0)
+	labelWidth := max(len(topLabel), len(bottomLabel))
+	var sb strings.Builder
+	for row := 0; row < height; row++ {
+		switch row {
+		case 0:
+			fmt.Fprintf(&sb, "%*s ┤", labelWidth, topLabel)
+		case height - 1:
+			fmt.Fprintf(&sb, "%*s ┤", labelWidth, bottomLabel)
+		default:
+			fmt.Fprintf(&sb, "%*s │", labelWidth, "")
+		}
+
+		base := (height - 1 - row) * len(chartBlocks)
+		for _, v := range cols {
+			var eighths int
+			if top > 0 {
+				eighths = int(math.Round(v / top * float64(height*len(chartBlocks))))
+			}
+			switch fill := eighths - base; {
+			case fill <= 0:
+				sb.WriteString(" ")
+			case fill >= len(chartBlocks):
+				sb.WriteString(chartBlocks[len(chartBlocks)-1])
+			default:
+				sb.WriteString(chartBlocks[fill-1])
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	end := span.String()
+	pad := max(len(cols)-len("0s")-len(end), 1)
+	fmt.Fprintf(&sb, "%*s  0s%s%s\n", labelWidth, "", strings.Repeat(" ", pad), end)
+	return sb.String()
+}
+
+func averageOf(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func maxOf(values []float64) float64 {
+	var m float64
+	for _, v := range values {
+		m = math.Max(m, v)
+	}
+	return m
+}
@@ -0,0 +1,74 @@
+package otchkiss
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChart(t *testing.T) {
+	t.Parallel()
+
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	testCases := map[string]struct {
+		values    []float64
+		height    int
+		width     int
+		aggregate func([]float64) float64
+		want      string
+	}{
+		"fits": {
+			values:    []float64{0, 2, 4, 6, 8, 10, 12, 14, 16},
+			height:    2,
+			width:     10,
+			aggregate: averageOf,
+			want: "16 ┤     ▂▄▆█\n" +
+				" 0 ┤ ▂▄▆█████\n" +
+				"    0s     9s\n",
+		},
+		"merged by average": {
+			values:    []float64{0, 2, 4, 6},
+			height:    1,
+			width:     2,
+			aggregate: averageOf,
+			want: "5 ┤▂█\n" +
+				"   0s 4s\n",
+		},
+		"merged by max": {
+			values:    []float64{0, 2, 4, 8},
+			height:    1,
+			width:     2,
+			aggregate: maxOf,
+			want: "8 ┤▂█\n" +
+				"   0s 4s\n",
+		},
+		"all zero": {
+			values:    []float64{0, 0},
+			height:    1,
+			width:     2,
+			aggregate: maxOf,
+			want: "0 ┤  \n" +
+				"   0s 2s\n",
+		},
+		"empty": {
+			values: nil,
+			height: 1,
+			width:  2,
+			want:   "",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			actual := chart(tc.values, tc.height, tc.width, time.Duration(len(tc.values))*time.Second, tc.aggregate, format)
+			assert.Equal(t, tc.want, actual)
+		})
+	}
+}
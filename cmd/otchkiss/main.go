@@ -0,0 +1,237 @@
+// Command otchkiss tests an HTTP target without writing Go, and works with the runs saved by Otchkiss.SaveFile.
+//
+//	otchkiss run [flags] -url <URL>
+//	otchkiss compare [flags] <base file> <head file>
+//
+// run sends the requests with the flags of setting.FromDefaultFlag (-p, -d, -w, -r and -n), and prints the report at the end.
+// compare prints the differences between two runs, and exits with 1 when any metric regressed.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/ryo-yamaoka/otchkiss"
+	"github.com/ryo-yamaoka/otchkiss/httpreq"
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/ryo-yamaoka/otchkiss/setting"
+	"github.com/ryo-yamaoka/otchkiss/threshold"
+)
+
+const (
+	exitOK        = 0
+	exitRegressed = 1
+	exitError     = 2
+)
+
+const usage = `usage:
+  otchkiss run [flags] -url <URL>
+  otchkiss compare [flags] <base file> <head file>`
+
+func main() {
+	// Interrupting stops the requests, and the report of the requests so far is printed.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code, err := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) (int, error) {
+	if len(args) == 0 {
+		return exitError, errors.New(usage)
+	}
+	switch args[0] {
+	case "run":
+		return runHTTP(ctx, args[1:], stdout, stderr)
+	case "compare":
+		return runCompare(args[1:], stdout, stderr)
+	default:
+		return exitError, fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
+
+func runHTTP(ctx context.Context, args []string, stdout, stderr io.Writer) (int, error) {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fromFlags := setting.DefineFlags(fs)
+	url := fs.String("url", "", "URL of the requests, ex: http://localhost:8080/path")
+	method := fs.String("method", http.MethodGet, "HTTP method of the requests")
+	header := headerFlag{}
+	fs.Var(header, "header", "Header of the requests, ex: 'Content-Type: application/json', can be repeated")
+	body := fs.String("body", "", "Body of the requests")
+	bodyFile := fs.String("body-file", "", "File of the body of the requests")
+	timeout := fs.Duration("timeout", 0, "Timeout of each request, ex: 10s. 0 means no limit")
+	jsonReport := fs.Bool("json", false, "Print the JSON report instead of the text report")
+	progress := fs.Bool("progress", false, "Show the progress on stderr while running")
+	if err := fs.Parse(args); err != nil {
+		return exitError, err
+	}
+	if fs.NArg() != 0 {
+		return exitError, errors.New(usage)
+	}
+	if *url == "" {
+		return exitError, errors.New("-url is required")
+	}
+	if *body != "" && *bodyFile != "" {
+		return exitError, errors.New("-body and -body-file cannot be used together")
+	}
+
+	st, err := fromFlags()
+	if err != nil {
+		return exitError, err
+	}
+	c := httpreq.Config{Method: *method, URL: *url, Header: http.Header(header), Timeout: *timeout}
+	if *body != "" {
+		c.Body = []byte(*body)
+	}
+	if *bodyFile != "" {
+		if c.Body, err = os.ReadFile(*bodyFile); err != nil {
+			return exitError, fmt.Errorf("failed to read body file: %w", err)
+		}
+	}
+	r, err := httpreq.New(c)
+	if err != nil {
+		return exitError, err
+	}
+	res, err := result.New()
+	if err != nil {
+		return exitError, err
+	}
+	res.SetErrorClassifier(httpreq.Classify)
+	ot, err := otchkiss.FromConfigWithResult(r, st, res)
+	if err != nil {
+		return exitError, err
+	}
+	if *progress {
+		ot.Progress = otchkiss.NewTerminalProgress(stderr)
+	}
+
+	if err := ot.Start(ctx); err != nil {
+		return exitError, err
+	}
+	if *jsonReport {
+		b, err := ot.JSONReport()
+		if err != nil {
+			return exitError, err
+		}
+		fmt.Fprintln(stdout, string(b))
+		return exitOK, nil
+	}
+	rep, err := ot.Report()
+	if err != nil {
+		return exitError, err
+	}
+	fmt.Fprintln(stdout, rep)
+	return exitOK, nil
+}
+
+// headerFlag is the repeatable flag of the headers in the form of "Key: Value".
+type headerFlag http.Header
+
+func (h headerFlag) String() string {
+	var headers []string
+	for k, vs := range h {
+		for _, v := range vs {
+			headers = append(headers, k+": "+v)
+		}
+	}
+	return strings.Join(headers, ", ")
+}
+
+func (h headerFlag) Set(s string) error {
+	k, v, ok := strings.Cut(s, ":")
+	if !ok || strings.TrimSpace(k) == "" {
+		return fmt.Errorf("invalid header %q: must be <key>: <value>", s)
+	}
+	http.Header(h).Add(strings.TrimSpace(k), strings.TrimSpace(v))
+	return nil
+}
+
+func runCompare(args []string, stdout, stderr io.Writer) (int, error) {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	alpha := fs.Float64("alpha", 0.05, "Significance level of the differences")
+	tolerance := fs.Float64("tolerance", 0, "How much a metric may get worse in percent before it regresses")
+	tolerances := fs.String("tolerances", "", "Tolerances of each metric overriding -tolerance, ex: p99=10,error_rate=50")
+	percentiles := fs.String("percentiles", "50,90,99", "Latency percentiles to compare")
+	if err := fs.Parse(args); err != nil {
+		return exitError, err
+	}
+	if fs.NArg() != 2 {
+		return exitError, errors.New(usage)
+	}
+
+	c := otchkiss.CompareConfig{Alpha: *alpha, Tolerance: *tolerance}
+	var err error
+	if c.Percentiles, err = parsePercentiles(*percentiles); err != nil {
+		return exitError, err
+	}
+	if c.Tolerances, err = parseTolerances(*tolerances); err != nil {
+		return exitError, err
+	}
+
+	base, err := otchkiss.LoadFile(fs.Arg(0))
+	if err != nil {
+		return exitError, fmt.Errorf("base: %w", err)
+	}
+	head, err := otchkiss.LoadFile(fs.Arg(1))
+	if err != nil {
+		return exitError, fmt.Errorf("head: %w", err)
+	}
+	cr, err := otchkiss.Compare(base, head, c)
+	if err != nil {
+		return exitError, err
+	}
+	rep, err := cr.Report()
+	if err != nil {
+		return exitError, err
+	}
+	fmt.Fprintln(stdout, rep)
+
+	if cr.Regressed() {
+		return exitRegressed, nil
+	}
+	return exitOK, nil
+}
+
+func parsePercentiles(s string) ([]int, error) {
+	var ps []int
+	for _, f := range strings.Split(s, ",") {
+		p, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return nil, fmt.Errorf("invalid percentile %q: %w", f, err)
+		}
+		ps = append(ps, p)
+	}
+	return ps, nil
+}
+
+func parseTolerances(s string) (map[threshold.Metric]float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	tolerances := make(map[threshold.Metric]float64)
+	for _, f := range strings.Split(s, ",") {
+		m, v, ok := strings.Cut(strings.TrimSpace(f), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid tolerance %q: must be <metric>=<percent>", f)
+		}
+		t, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tolerance %q: %w", f, err)
+		}
+		tolerances[threshold.Metric(m)] = t
+	}
+	return tolerances, nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss"
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/ryo-yamaoka/otchkiss/setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	save := func(name string, slowdown float64) string {
+		r, err := result.WithCapacity(0)
+		require.NoError(t, err)
+		for i := 0; i < 200; i++ {
+			r.AppendSuccess((0.1 + float64(i%10)*0.001) * slowdown)
+		}
+		ot := &otchkiss.Otchkiss{Result: r, Setting: &setting.Setting{RunDuration: 2 * time.Second}}
+		path := filepath.Join(dir, name)
+		require.NoError(t, ot.SaveFile(path))
+		return path
+	}
+	base, slower := save("base", 1), save("slower", 1.2)
+
+	testCases := map[string]struct {
+		args     []string
+		wantCode int
+	}{
+		"same": {
+			args:     []string{"compare", base, base},
+			wantCode: exitOK,
+		},
+		"regressed": {
+			args:     []string{"compare", base, slower},
+			wantCode: exitRegressed,
+		},
+		"within tolerance": {
+			args:     []string{"compare", "-tolerance", "30", base, slower},
+			wantCode: exitOK,
+		},
+		"tolerances": {
+			args:     []string{"compare", "-tolerance", "30", "-tolerances", "p99=10%", base, slower},
+			wantCode: exitRegressed,
+		},
+		"no file": {
+			args:     []string{"compare", base, filepath.Join(dir, "none")},
+			wantCode: exitError,
+		},
+		"invalid percentiles": {
+			args:     []string{"compare", "-percentiles", "x", base, base},
+			wantCode: exitError,
+		},
+		"no command": {
+			args:     nil,
+			wantCode: exitError,
+		},
+		"unknown command": {
+			args:     []string{"diff", base, base},
+			wantCode: exitError,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			var stdout, stderr bytes.Buffer
+			code, err := run(context.Background(), tc.args, &stdout, &stderr)
+			assert.Equal(t, tc.wantCode, code)
+			assert.Equal(t, tc.wantCode == exitError, err != nil)
+			if tc.wantCode != exitError {
+				assert.Contains(t, stdout.String(), "[Comparison: ")
+			}
+		})
+	}
+}
+
+func TestRunHTTP(t *testing.T) {
+	t.Parallel()
+
+	bodyFile := filepath.Join(t.TempDir(), "body.json")
+	require.NoError(t, os.WriteFile(bodyFile, []byte(`{"id":1}`), 0o600))
+
+	testCases := map[string]struct {
+		args       []string
+		wantCode   int
+		wantOutput string
+		wantMethod string
+		wantHeader string
+		wantBody   string
+	}{
+		"GET": {
+			args:       []string{"run", "-n", "5", "-w", "0s", "-r", "0", "-url", "{url}"},
+			wantCode:   exitOK,
+			wantOutput: "* total:      5\n",
+			wantMethod: http.MethodGet,
+		},
+		"POST": {
+			args:       []string{"run", "-n", "5", "-w", "0s", "-r", "0", "-method", "POST", "-header", "X-Test: a", "-header", "Content-Type: text/plain", "-body", "hello", "-url", "{url}"},
+			wantCode:   exitOK,
+			wantOutput: "* total:      5\n",
+			wantMethod: http.MethodPost,
+			wantHeader: "a",
+			wantBody:   "hello",
+		},
+		"body file": {
+			args:       []string{"run", "-n", "5", "-w", "0s", "-r", "0", "-method", "PUT", "-body-file", bodyFile, "-url", "{url}"},
+			wantCode:   exitOK,
+			wantOutput: "* total:      5\n",
+			wantMethod: http.MethodPut,
+			wantBody:   `{"id":1}`,
+		},
+		"no url": {
+			args:     []string{"run", "-n", "5"},
+			wantCode: exitError,
+		},
+		"body and body file": {
+			args:     []string{"run", "-body", "hello", "-body-file", bodyFile, "-url", "{url}"},
+			wantCode: exitError,
+		},
+		"no body file": {
+			args:     []string{"run", "-body-file", filepath.Join(t.TempDir(), "none"), "-url", "{url}"},
+			wantCode: exitError,
+		},
+		"invalid header": {
+			args:     []string{"run", "-header", "X-Test", "-url", "{url}"},
+			wantCode: exitError,
+		},
+		"invalid setting": {
+			args:     []string{"run", "-p", "-1", "-url", "{url}"},
+			wantCode: exitError,
+		},
+		"extra argument": {
+			args:     []string{"run", "-url", "{url}", "extra"},
+			wantCode: exitError,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			var method, header, body string
+			srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+				b, _ := io.ReadAll(req.Body)
+				mu.Lock()
+				defer mu.Unlock()
+				method, header, body = req.Method, req.Header.Get("X-Test"), string(b)
+			}))
+			defer srv.Close()
+
+			args := make([]string, 0, len(tc.args))
+			for _, a := range tc.args {
+				if a == "{url}" {
+					a = srv.URL
+				}
+				args = append(args, a)
+			}
+			var stdout, stderr bytes.Buffer
+			code, err := run(context.Background(), args, &stdout, &stderr)
+			assert.Equal(t, tc.wantCode, code)
+			if tc.wantCode == exitError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Contains(t, stdout.String(), "[Request]\n"+tc.wantOutput)
+			assert.Contains(t, stdout.String(), "[Timings (ms)]")
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, tc.wantMethod, method)
+			assert.Equal(t, tc.wantHeader, header)
+			assert.Equal(t, tc.wantBody, body)
+		})
+	}
+}
+
+func TestRunHTTPJSON(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	var stdout, stderr bytes.Buffer
+	code, err := run(context.Background(), []string{"run", "-n", "3", "-w", "0s", "-r", "0", "-json", "-url", srv.URL}, &stdout, &stderr)
+	require.NoError(t, err)
+	assert.Equal(t, exitOK, code)
+
+	var jr otchkiss.JSONReport
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &jr))
+	assert.Equal(t, int64(3), jr.Requests.Failed)
+	require.Len(t, jr.Errors, 1)
+	assert.Equal(t, "status 503", jr.Errors[0].Key)
+}
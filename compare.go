@@ -0,0 +1,354 @@
+package otchkiss
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/ryo-yamaoka/otchkiss/threshold"
+
+	humanize "github.com/dustin/go-humanize"
+)
+
+// CompareConfig defines how Compare judges the differences between two runs.
+type CompareConfig struct {
+	// Percentiles defines the latency percentiles to compare.
+	// nil means 50, 90 and 99.
+	Percentiles []int
+
+	// Alpha defines the significance level, a difference whose p-value is below it is significant.
+	// 0 means 0.05.
+	Alpha float64
+
+	// Tolerance defines how much a metric may get worse in percent before the significant difference is a regression.
+	// 0 means any significant worsening is a regression.
+	Tolerance float64
+
+	// Tolerances overrides Tolerance for each metric, such as {threshold.Percentile(99): 10}.
+	Tolerances map[threshold.Metric]float64
+}
+
+func (c *CompareConfig) validate() error {
+	for _, p := range c.Percentiles {
+		if !(p >= 0 && p <= 100) {
+			return errors.New("percentile must be between 0 and 100")
+		}
+	}
+	if !(c.Alpha >= 0 && c.Alpha < 1) {
+		return errors.New("alpha must be >= 0 and < 1")
+	}
+	if !(c.Tolerance >= 0) {
+		return errors.New("tolerance must be >= 0")
+	}
+	for m, t := range c.Tolerances {
+		if !(t >= 0) {
+			return fmt.Errorf("tolerance of %s must be >= 0", m)
+		}
+	}
+	return nil
+}
+
+func (c *CompareConfig) tolerance(m threshold.Metric) float64 {
+	if t, ok := c.Tolerances[m]; ok {
+		return t
+	}
+	return c.Tolerance
+}
+
+// ComparisonRow is the difference of a metric between two runs.
+type ComparisonRow struct {
+	Metric threshold.Metric
+
+	// Base and Head are the values of the metric in the unit of threshold.Metric.
+	Base float64
+	Head float64
+
+	// Delta is the change from Base to Head in percent, it is +Inf when Base is 0 and Head is not.
+	Delta float64
+
+	// P is the p-value of the difference, it is 1 when there are too few samples to test.
+	// The mean latency is tested by the Mann-Whitney U test, each latency percentile by the quantile test of its own,
+	// the error rate by the two-proportion z-test, and RPS by the Mann-Whitney U test on the RPS of each interval of the time series.
+	P float64
+
+	Significant bool
+
+	// Worse reports whether the metric got worse, that is higher latency or error rate, or lower RPS.
+	Worse bool
+
+	// Regressed reports whether the metric got significantly worse beyond the tolerance.
+	Regressed bool
+}
+
+// Comparison is the differences between two runs.
+type Comparison struct {
+	Rows []ComparisonRow
+}
+
+// Regressed reports whether any metric regressed.
+func (c *Comparison) Regressed() bool {
+	for _, r := range c.Rows {
+		if r.Regressed {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare returns the differences of RPS, error rate and latencies from base to head, such as the runs loaded by LoadFile.
+func Compare(base, head *Otchkiss, c CompareConfig) (*Comparison, error) {
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid compare config: %w", err)
+	}
+	if len(c.Percentiles) == 0 {
+		c.Percentiles = []int{50, 90, 99}
+	}
+	if c.Alpha == 0 {
+		c.Alpha = 0.05
+	}
+	if base.Result.Succeeded()+base.Result.Failed() == 0 {
+		return nil, errors.New("no result data in base")
+	}
+	if head.Result.Succeeded()+head.Result.Failed() == 0 {
+		return nil, errors.New("no result data in head")
+	}
+
+	cr := &Comparison{}
+	add := func(m threshold.Metric, p float64) error {
+		v := threshold.Evaluate(base.Result, base.measuredDuration(), []threshold.Threshold{{Metric: m, Op: threshold.Less}})
+		b, err := v.Outcomes[0].Observed, v.Outcomes[0].Err
+		if err != nil {
+			return fmt.Errorf("failed to observe %s of base: %w", m, err)
+		}
+		v = threshold.Evaluate(head.Result, head.measuredDuration(), []threshold.Threshold{{Metric: m, Op: threshold.Less}})
+		h, err := v.Outcomes[0].Observed, v.Outcomes[0].Err
+		if err != nil {
+			return fmt.Errorf("failed to observe %s of head: %w", m, err)
+		}
+
+		row := ComparisonRow{Metric: m, Base: b, Head: h, Delta: delta(b, h), P: p}
+		row.Significant = p < c.Alpha
+		if m == threshold.RPS {
+			row.Worse = h < b
+		} else {
+			row.Worse = h > b
+		}
+		row.Regressed = row.Significant && row.Worse && math.Abs(row.Delta) > c.tolerance(m)
+		cr.Rows = append(cr.Rows, row)
+		return nil
+	}
+
+	if err := add(threshold.RPS, mannWhitney(intervalRPS(base.Result), intervalRPS(head.Result))); err != nil {
+		return nil, err
+	}
+	bn, hn := base.Result.Succeeded()+base.Result.Failed(), head.Result.Succeeded()+head.Result.Failed()
+	if err := add(threshold.ErrorRate, twoProportion(base.Result.Failed(), bn, head.Result.Failed(), hn)); err != nil {
+		return nil, err
+	}
+	bc, hc := base.Result.LatencyCounts(), head.Result.LatencyCounts()
+	if err := add(threshold.MeanLatency, mannWhitney(bc, hc)); err != nil {
+		return nil, err
+	}
+	for _, pc := range c.Percentiles {
+		if err := add(threshold.Percentile(pc), quantileTest(bc, hc, pc)); err != nil {
+			return nil, err
+		}
+	}
+	return cr, nil
+}
+
+// delta returns the change from b to h in percent.
+func delta(b, h float64) float64 {
+	if b == 0 {
+		if h == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return (h - b) / b * 100
+}
+
+// intervalRPS returns the distribution of the RPS of each interval of the time series.
+// The last interval is excluded because the test usually ends in the middle of it.
+func intervalRPS(r *result.Result) []result.LatencyCount {
+	points := r.TimeSeries()
+	if len(points) != 0 {
+		points = points[:len(points)-1]
+	}
+	rps := make([]float64, 0, len(points))
+	for _, p := range points {
+		rps = append(rps, p.RPS())
+	}
+	sort.Float64s(rps)
+
+	var counts []result.LatencyCount
+	for _, v := range rps {
+		if n := len(counts); n != 0 && counts[n-1].Value == v {
+			counts[n-1].Count++
+		} else {
+			counts = append(counts, result.LatencyCount{Value: v, Count: 1})
+		}
+	}
+	return counts
+}
+
+// mannWhitney returns the two-sided p-value of the Mann-Whitney U test between the distributions x and y in ascending order.
+// It uses the normal approximation with the tie correction, so the equal values such as the buckets of the histograms are ranked together.
+func mannWhitney(x, y []result.LatencyCount) float64 {
+	var n1, n2 int64
+	for _, c := range x {
+		n1 += c.Count
+	}
+	for _, c := range y {
+		n2 += c.Count
+	}
+	if n1 < 2 || n2 < 2 {
+		return 1
+	}
+
+	// r1 is the rank sum of x, and ties is the sum of t^3-t of each group of t equal values.
+	var r1, ties, rank float64
+	for i, j := 0, 0; i < len(x) || j < len(y); {
+		var v float64
+		if j >= len(y) || (i < len(x) && x[i].Value <= y[j].Value) {
+			v = x[i].Value
+		} else {
+			v = y[j].Value
+		}
+		var cx, cy int64
+		if i < len(x) && x[i].Value == v {
+			cx = x[i].Count
+			i++
+		}
+		if j < len(y) && y[j].Value == v {
+			cy = y[j].Count
+			j++
+		}
+		t := float64(cx + cy)
+		r1 += float64(cx) * (rank + (t+1)/2)
+		ties += t*t*t - t
+		rank += t
+	}
+
+	fn1, fn2 := float64(n1), float64(n2)
+	n := fn1 + fn2
+	u := r1 - fn1*(fn1+1)/2
+	variance := fn1 * fn2 / 12 * ((n + 1) - ties/(n*(n-1)))
+	if variance <= 0 {
+		return 1 // All the values are equal.
+	}
+	z := (u - fn1*fn2/2) / math.Sqrt(variance)
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// quantileTest returns the two-sided p-value of the quantile test of the p-th percentile between the distributions x and y in ascending order.
+// It finds the p-th percentile of x and y together, and tests whether the proportions of the values below it differ,
+// so it detects a change of the tail even when the rest of the distribution is the same.
+// The values equal to the percentile, such as a bucket of the histograms, are counted on either side,
+// and the smaller p-value is doubled to keep the test conservative.
+func quantileTest(x, y []result.LatencyCount, p int) float64 {
+	var n1, n2 int64
+	for _, c := range x {
+		n1 += c.Count
+	}
+	for _, c := range y {
+		n2 += c.Count
+	}
+	if n1 < 2 || n2 < 2 {
+		return 1
+	}
+
+	// m is the pooled percentile, the value of the k-th smallest of all.
+	k := max(int64(math.Ceil(float64(n1+n2)*float64(p)/100)), 1)
+	var m float64
+	var seen int64
+	for i, j := 0, 0; seen < k; {
+		if j >= len(y) || (i < len(x) && x[i].Value <= y[j].Value) {
+			m = x[i].Value
+			seen += x[i].Count
+			i++
+		} else {
+			m = y[j].Value
+			seen += y[j].Count
+			j++
+		}
+	}
+
+	// below returns the number of the values below m, and the one including m.
+	below := func(counts []result.LatencyCount) (lt, le int64) {
+		for _, c := range counts {
+			if c.Value < m {
+				lt += c.Count
+			}
+			if c.Value <= m {
+				le += c.Count
+			}
+		}
+		return lt, le
+	}
+	lt1, le1 := below(x)
+	lt2, le2 := below(y)
+	pv := min(twoProportion(lt1, n1, lt2, n2), twoProportion(le1, n1, le2, n2))
+	return min(2*pv, 1)
+}
+
+// twoProportion returns the two-sided p-value of the two-proportion z-test between k1 of n1 and k2 of n2.
+func twoProportion(k1, n1, k2, n2 int64) float64 {
+	fn1, fn2 := float64(n1), float64(n2)
+	f1, f2 := float64(k1), float64(k2)
+	p := (f1 + f2) / (fn1 + fn2)
+	se := math.Sqrt(p * (1 - p) * (1/fn1 + 1/fn2))
+	if se == 0 {
+		return 1 // Both have all or none of them.
+	}
+	z := (f2/fn2 - f1/fn1) / se
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// Report returns the verdict and the table of the differences, where "~" means no significant difference.
+func (c *Comparison) Report() (string, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "\n[Comparison: %s]\n\n", passOrFail(!c.Regressed()))
+
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "metric\tbase\thead\tdelta\tp\tresult\t")
+	for _, r := range c.Rows {
+		res := "~"
+		switch {
+		case r.Regressed:
+			res = "regressed"
+		case r.Significant && r.Worse:
+			res = "worse"
+		case r.Significant:
+			res = "improved"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%+.1f%%\t%.3f\t%s\t\n",
+			r.Metric,
+			formatMetric(r.Metric, r.Base),
+			formatMetric(r.Metric, r.Head),
+			r.Delta,
+			r.P,
+			res,
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// formatMetric returns v in the same format as the report.
+func formatMetric(m threshold.Metric, v float64) string {
+	switch m {
+	case threshold.RPS:
+		return humanize.CommafWithDigits(v, 1)
+	case threshold.ErrorRate:
+		return humanize.CommafWithDigits(v, 1) + " %"
+	default:
+		return humanize.CommafWithDigits(v*1000, 1) + " ms"
+	}
+}
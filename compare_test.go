@@ -0,0 +1,228 @@
+package otchkiss
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/ryo-yamaoka/otchkiss/setting"
+	"github.com/ryo-yamaoka/otchkiss/threshold"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMannWhitney(t *testing.T) {
+	t.Parallel()
+
+	counts := func(values ...float64) []result.LatencyCount {
+		var c []result.LatencyCount
+		for _, v := range values {
+			c = append(c, result.LatencyCount{Value: v, Count: 1})
+		}
+		return c
+	}
+
+	testCases := map[string]struct {
+		x, y  []result.LatencyCount
+		wantP float64
+	}{
+		"separated": {
+			x:     counts(1, 2, 3, 4, 5),
+			y:     counts(6, 7, 8, 9, 10),
+			wantP: 0.0090,
+		},
+		"same": {
+			x:     counts(1, 2, 3, 4, 5),
+			y:     counts(1, 2, 3, 4, 5),
+			wantP: 1,
+		},
+		"ties": {
+			x:     []result.LatencyCount{{Value: 1, Count: 3}, {Value: 2, Count: 2}},
+			y:     []result.LatencyCount{{Value: 2, Count: 2}, {Value: 3, Count: 3}},
+			wantP: 0.0201,
+		},
+		"all equal": {
+			x:     []result.LatencyCount{{Value: 1, Count: 5}},
+			y:     []result.LatencyCount{{Value: 1, Count: 5}},
+			wantP: 1,
+		},
+		"too few": {
+			x:     counts(1),
+			y:     counts(2, 3),
+			wantP: 1,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			assert.InDelta(t, tc.wantP, mannWhitney(tc.x, tc.y), 0.0001)
+			assert.InDelta(t, tc.wantP, mannWhitney(tc.y, tc.x), 0.0001, "must be symmetric")
+		})
+	}
+}
+
+func TestQuantileTest(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		x, y       []result.LatencyCount
+		percentile int
+		wantP      float64
+	}{
+		"same": {
+			x:          []result.LatencyCount{{Value: 1, Count: 50}, {Value: 2, Count: 50}},
+			y:          []result.LatencyCount{{Value: 1, Count: 50}, {Value: 2, Count: 50}},
+			percentile: 50,
+			wantP:      1,
+		},
+		"shifted median": {
+			x:          []result.LatencyCount{{Value: 1, Count: 60}, {Value: 2, Count: 40}},
+			y:          []result.LatencyCount{{Value: 1, Count: 40}, {Value: 2, Count: 60}},
+			percentile: 50,
+			wantP:      0.0094,
+		},
+		"same median, slower tail": {
+			x:          []result.LatencyCount{{Value: 1, Count: 90}, {Value: 2, Count: 10}},
+			y:          []result.LatencyCount{{Value: 1, Count: 90}, {Value: 9, Count: 10}},
+			percentile: 50,
+			wantP:      1,
+		},
+		"slower tail": {
+			x:          []result.LatencyCount{{Value: 1, Count: 90}, {Value: 2, Count: 10}},
+			y:          []result.LatencyCount{{Value: 1, Count: 90}, {Value: 9, Count: 10}},
+			percentile: 99,
+			wantP:      0.0024,
+		},
+		"too few": {
+			x:          []result.LatencyCount{{Value: 1, Count: 1}},
+			y:          []result.LatencyCount{{Value: 2, Count: 2}},
+			percentile: 50,
+			wantP:      1,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			assert.InDelta(t, tc.wantP, quantileTest(tc.x, tc.y, tc.percentile), 0.0001)
+			assert.InDelta(t, tc.wantP, quantileTest(tc.y, tc.x, tc.percentile), 0.0001, "must be symmetric")
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	// run returns the run whose latencies are 100ms to 109ms scaled by slowdown, and fails the given number of requests.
+	run := func(slowdown float64, failures int) *Otchkiss {
+		r, _ := result.WithCapacity(0)
+		for i := 0; i < 200; i++ {
+			v := (0.1 + float64(i%10)*0.001) * slowdown
+			if i < failures {
+				r.AppendFail(v, errors.New("err"))
+			} else {
+				r.AppendSuccess(v)
+			}
+		}
+		return &Otchkiss{Result: r, Setting: &setting.Setting{RunDuration: 2 * time.Second}}
+	}
+	// slowTail returns the run whose slowest tenth takes 500ms instead of 109ms, so only the tail changes.
+	slowTail := func() *Otchkiss {
+		ot := run(1, 0)
+		ot.Result, _ = result.WithCapacity(0)
+		for i := 0; i < 200; i++ {
+			v := 0.1 + float64(i%10)*0.001
+			if i%10 == 9 {
+				v = 0.5
+			}
+			ot.Result.AppendSuccess(v)
+		}
+		return ot
+	}
+
+	testCases := map[string]struct {
+		head          *Otchkiss
+		config        CompareConfig
+		wantRegressed map[threshold.Metric]bool
+	}{
+		"same": {
+			head:          run(1, 0),
+			wantRegressed: map[threshold.Metric]bool{},
+		},
+		"slower": {
+			head: run(1.2, 0),
+			wantRegressed: map[threshold.Metric]bool{
+				threshold.MeanLatency:    true,
+				threshold.Percentile(50): true,
+				threshold.Percentile(90): true,
+				threshold.Percentile(99): true,
+			},
+		},
+		"slower within tolerance": {
+			head:          run(1.2, 0),
+			config:        CompareConfig{Tolerance: 10, Tolerances: map[threshold.Metric]float64{threshold.Percentile(99): 30}},
+			wantRegressed: map[threshold.Metric]bool{threshold.MeanLatency: true, threshold.Percentile(50): true, threshold.Percentile(90): true},
+		},
+		"faster": {
+			head:          run(0.8, 0),
+			wantRegressed: map[threshold.Metric]bool{},
+		},
+		"slower tail": {
+			head:          slowTail(),
+			wantRegressed: map[threshold.Metric]bool{threshold.Percentile(99): true},
+		},
+		"more errors": {
+			head:          run(1, 20),
+			wantRegressed: map[threshold.Metric]bool{threshold.ErrorRate: true},
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			cr, err := Compare(run(1, 0), tc.head, tc.config)
+			require.NoError(t, err)
+			require.Len(t, cr.Rows, 6)
+
+			regressed := map[threshold.Metric]bool{}
+			for _, r := range cr.Rows {
+				if r.Regressed {
+					regressed[r.Metric] = true
+				}
+			}
+			assert.Equal(t, tc.wantRegressed, regressed)
+			assert.Equal(t, len(tc.wantRegressed) != 0, cr.Regressed())
+
+			report, err := cr.Report()
+			require.NoError(t, err)
+			assert.Equal(t, len(tc.wantRegressed) != 0, strings.Contains(report, "[Comparison: FAIL]"))
+		})
+	}
+}
+
+func TestCompareInvalid(t *testing.T) {
+	t.Parallel()
+
+	r, err := result.WithCapacity(0)
+	require.NoError(t, err)
+	r.AppendSuccess(0.1)
+	empty, err := result.WithCapacity(0)
+	require.NoError(t, err)
+	st := &setting.Setting{RunDuration: time.Second}
+
+	_, err = Compare(&Otchkiss{Result: r, Setting: st}, &Otchkiss{Result: empty, Setting: st}, CompareConfig{})
+	assert.Error(t, err)
+	_, err = Compare(&Otchkiss{Result: r, Setting: st}, &Otchkiss{Result: r, Setting: st}, CompareConfig{Alpha: 1})
+	assert.Error(t, err)
+	_, err = Compare(&Otchkiss{Result: r, Setting: st}, &Otchkiss{Result: r, Setting: st}, CompareConfig{Percentiles: []int{101}})
+	assert.Error(t, err)
+}
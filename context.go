@@ -0,0 +1,96 @@
+package otchkiss
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+)
+
+type (
+	recorderKey    struct{}
+	virtualUserKey struct{}
+)
+
+// recorder collects what RequestOne attaches to its outcome through the context.
+type recorder struct {
+	mu      sync.Mutex
+	tags    []result.Tag
+	timings []timing
+}
+
+// timing is the duration of a named part of RequestOne.
+type timing struct {
+	name string
+	d    time.Duration
+}
+
+func withRecorder(ctx context.Context) (context.Context, *recorder) {
+	rec := &recorder{}
+	return context.WithValue(ctx, recorderKey{}, rec), rec
+}
+
+func recorderFrom(ctx context.Context) *recorder {
+	rec, _ := ctx.Value(recorderKey{}).(*recorder)
+	return rec
+}
+
+// Tag attaches key=value to the outcome of the RequestOne called with ctx, for example the endpoint name or the method.
+// The outcome is also recorded in Result.Tagged() of each tag, and the report shows the statistics per tag.
+// When the same key is attached again, the value is overwritten.
+// It does nothing if ctx is not the one passed to RequestOne.
+func Tag(ctx context.Context, key, value string) {
+	rec := recorderFrom(ctx)
+	if rec == nil {
+		return
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	for i, t := range rec.tags {
+		if t.Key == key {
+			rec.tags[i].Value = value
+			return
+		}
+	}
+	rec.tags = append(rec.tags, result.Tag{Key: key, Value: value})
+}
+
+func (rec *recorder) recordedTags() []result.Tag {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]result.Tag(nil), rec.tags...)
+}
+
+// Timing records d as the duration of the named part of the RequestOne called with ctx, for example the DNS lookup of HTTP.
+// The durations of the measured requests are recorded in Result.Timing() of each name, and the report shows their percentiles.
+// A part can be timed more than once by a RequestOne, and each is recorded.
+// It does nothing if ctx is not the one passed to RequestOne.
+func Timing(ctx context.Context, name string, d time.Duration) {
+	rec := recorderFrom(ctx)
+	if rec == nil {
+		return
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.timings = append(rec.timings, timing{name: name, d: d})
+}
+
+func (rec *recorder) recordedTimings() []timing {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]timing(nil), rec.timings...)
+}
+
+func withVirtualUser(ctx context.Context, vu int) context.Context {
+	return context.WithValue(ctx, virtualUserKey{}, vu)
+}
+
+// VirtualUser returns the number of the virtual user which calls RequestOne with ctx.
+// ok is false when Otchkiss does not run virtual users.
+func VirtualUser(ctx context.Context) (vu int, ok bool) {
+	vu, ok = ctx.Value(virtualUserKey{}).(int)
+	return vu, ok
+}
@@ -0,0 +1,82 @@
+package otchkiss
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTag(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		tags [][2]string
+		want []result.Tag
+	}{
+		"single": {
+			tags: [][2]string{{"endpoint", "search"}},
+			want: []result.Tag{{Key: "endpoint", Value: "search"}},
+		},
+		"multiple": {
+			tags: [][2]string{{"endpoint", "search"}, {"method", "GET"}},
+			want: []result.Tag{{Key: "endpoint", Value: "search"}, {Key: "method", Value: "GET"}},
+		},
+		"overwrite": {
+			tags: [][2]string{{"endpoint", "search"}, {"method", "GET"}, {"endpoint", "browse"}},
+			want: []result.Tag{{Key: "endpoint", Value: "browse"}, {Key: "method", Value: "GET"}},
+		},
+		"none": {
+			tags: nil,
+			want: nil,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, rec := withRecorder(context.Background())
+			for _, kv := range tc.tags {
+				Tag(ctx, kv[0], kv[1])
+			}
+			assert.Equal(t, tc.want, rec.recordedTags())
+		})
+	}
+}
+
+func TestTagWithoutRecorder(t *testing.T) {
+	t.Parallel()
+
+	assert.NotPanics(t, func() {
+		Tag(context.Background(), "endpoint", "search")
+	})
+}
+
+func TestTiming(t *testing.T) {
+	t.Parallel()
+
+	ctx, rec := withRecorder(context.Background())
+	Timing(ctx, "dns", time.Millisecond)
+	Timing(ctx, "connect", 2*time.Millisecond)
+	Timing(ctx, "dns", 3*time.Millisecond)
+	assert.Equal(t, []timing{{name: "dns", d: time.Millisecond}, {name: "connect", d: 2 * time.Millisecond}, {name: "dns", d: 3 * time.Millisecond}}, rec.recordedTimings())
+
+	assert.NotPanics(t, func() {
+		Timing(context.Background(), "dns", time.Millisecond)
+	})
+}
+
+func TestVirtualUser(t *testing.T) {
+	t.Parallel()
+
+	_, ok := VirtualUser(context.Background())
+	assert.False(t, ok)
+
+	vu, ok := VirtualUser(withVirtualUser(context.Background(), 3))
+	assert.True(t, ok)
+	assert.Equal(t, 3, vu)
+}
@@ -0,0 +1,113 @@
+package httpreq
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"syscall"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+)
+
+// Assertion checks a response, and returns an error to count the request as a failure.
+// body is the whole response body, and it must not be retained after the call.
+type Assertion func(resp *http.Response, body []byte) error
+
+// StatusError is the failure because of an unexpected status code.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status: %s", e.Status)
+}
+
+// BodyError is the failure because of an unexpected response body.
+type BodyError struct {
+	Reason string
+}
+
+func (e *BodyError) Error() string {
+	return fmt.Sprintf("unexpected body: %s", e.Reason)
+}
+
+// StatusSuccess returns Assertion which fails with StatusError unless the status code is 2xx.
+func StatusSuccess() Assertion {
+	return func(resp *http.Response, _ []byte) error {
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+		}
+		return nil
+	}
+}
+
+// Status returns Assertion which fails with StatusError unless the status code is one of codes.
+func Status(codes ...int) Assertion {
+	codes = slices.Clone(codes)
+	return func(resp *http.Response, _ []byte) error {
+		if !slices.Contains(codes, resp.StatusCode) {
+			return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+		}
+		return nil
+	}
+}
+
+// BodyContains returns Assertion which fails with BodyError unless the body contains s.
+func BodyContains(s string) Assertion {
+	sub := []byte(s)
+	return func(_ *http.Response, body []byte) error {
+		if !bytes.Contains(body, sub) {
+			return &BodyError{Reason: fmt.Sprintf("does not contain %q", s)}
+		}
+		return nil
+	}
+}
+
+// BodyMatches returns Assertion which fails with BodyError unless the body matches re.
+func BodyMatches(re *regexp.Regexp) Assertion {
+	return func(_ *http.Response, body []byte) error {
+		if !re.Match(body) {
+			return &BodyError{Reason: fmt.Sprintf("does not match %q", re)}
+		}
+		return nil
+	}
+}
+
+// BodyFunc returns Assertion which fails with BodyError of the reason returned by f, for example when the body is not the expected JSON.
+func BodyFunc(f func(body []byte) error) Assertion {
+	return func(_ *http.Response, body []byte) error {
+		if err := f(body); err != nil {
+			return &BodyError{Reason: err.Error()}
+		}
+		return nil
+	}
+}
+
+// Classify is result.Classifier which groups the errors of Requester by their category.
+// The keys are "status 503" for StatusError, "timeout", "connection refused" and "connection reset" for the network errors,
+// the message for BodyError, and the others are classified by result.DefaultClassifier.
+func Classify(err error) string {
+	var se *StatusError
+	var be *BodyError
+	var ne net.Error
+	switch {
+	case errors.As(err, &se):
+		return fmt.Sprintf("status %d", se.StatusCode)
+	case errors.As(err, &be):
+		return be.Error()
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &ne) && ne.Timeout():
+		return "timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "connection reset"
+	default:
+		return result.DefaultClassifier(err)
+	}
+}
@@ -0,0 +1,131 @@
+package httpreq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssertions(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		assertion Assertion
+		status    int
+		body      string
+		want      error
+	}{
+		"success: 204": {
+			assertion: StatusSuccess(),
+			status:    http.StatusNoContent,
+		},
+		"success: 302": {
+			assertion: StatusSuccess(),
+			status:    http.StatusFound,
+			want:      &StatusError{StatusCode: http.StatusFound, Status: "302 Found"},
+		},
+		"status: listed": {
+			assertion: Status(http.StatusOK, http.StatusNotFound),
+			status:    http.StatusNotFound,
+		},
+		"status: not listed": {
+			assertion: Status(http.StatusOK),
+			status:    http.StatusInternalServerError,
+			want:      &StatusError{StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error"},
+		},
+		"contains": {
+			assertion: BodyContains(`"ok":true`),
+			body:      `{"ok":true}`,
+		},
+		"contains: missing": {
+			assertion: BodyContains(`"ok":true`),
+			body:      `{"ok":false}`,
+			want:      &BodyError{Reason: `does not contain "\"ok\":true"`},
+		},
+		"matches": {
+			assertion: BodyMatches(regexp.MustCompile(`^id=\d+$`)),
+			body:      "id=123",
+		},
+		"matches: mismatch": {
+			assertion: BodyMatches(regexp.MustCompile(`^id=\d+$`)),
+			body:      "id=abc",
+			want:      &BodyError{Reason: `does not match "^id=\\d+$"`},
+		},
+		"func": {
+			assertion: BodyFunc(func(body []byte) error {
+				if len(body) == 0 {
+					return errors.New("empty")
+				}
+				return nil
+			}),
+			body: "",
+			want: &BodyError{Reason: "empty"},
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			resp := &http.Response{StatusCode: tc.status, Status: fmt.Sprintf("%d %s", tc.status, http.StatusText(tc.status))}
+			assert.Equal(t, tc.want, tc.assertion(resp, []byte(tc.body)))
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	// A closed port refuses the connection.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	r, err := New(Config{URL: "http://" + addr})
+	require.NoError(t, err)
+	refused := r.RequestOne(context.Background())
+	require.Error(t, refused)
+
+	testCases := map[string]struct {
+		err  error
+		want string
+	}{
+		"status": {
+			err:  &StatusError{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"},
+			want: "status 503",
+		},
+		"body": {
+			err:  fmt.Errorf("wrapped: %w", &BodyError{Reason: "empty"}),
+			want: "unexpected body: empty",
+		},
+		"deadline": {
+			err:  fmt.Errorf("wrapped: %w", context.DeadlineExceeded),
+			want: "timeout",
+		},
+		"refused": {
+			err:  refused,
+			want: "connection refused",
+		},
+		"other": {
+			err:  errors.New("other"),
+			want: "other",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.want, Classify(tc.err))
+		})
+	}
+}
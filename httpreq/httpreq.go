@@ -0,0 +1,260 @@
+// Package httpreq provides Requester which sends an HTTP request on each RequestOne.
+package httpreq
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config defines the requests which Requester sends and how it connects.
+type Config struct {
+	// Method is the HTTP method.
+	// "" means GET.
+	Method string
+
+	// URL is the absolute http or https URL of the requests.
+	URL string
+
+	// Header is sent with every request.
+	// The Host header overrides the host of URL.
+	Header http.Header
+
+	// Body is sent as is with every request.
+	// It is ignored when BodyFunc is specified.
+	Body []byte
+
+	// BodyFunc returns the body of each request, for example to send a different ID every time.
+	// iteration counts the requests of the Requester from 0, and it is called concurrently.
+	// If an error is returned, the request is not sent and counted as a failure.
+	BodyFunc func(ctx context.Context, iteration int64) ([]byte, error)
+
+	// Timeout limits each request including reading the response body.
+	// 0 means no limit.
+	Timeout time.Duration
+
+	// DialTimeout, TLSHandshakeTimeout and ResponseHeaderTimeout limit the parts of each request.
+	// 0 means no limit, except that DialTimeout is 30s and TLSHandshakeTimeout is 10s as the default of net/http.
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+
+	// MaxIdleConnsPerHost defines how many idle connections are kept to be reused.
+	// 0 means the same as MaxConnsPerHost, or 100 when it is also 0.
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost defines how many connections are used at once, the requests wait for one when all are used.
+	// 0 means unlimited.
+	MaxConnsPerHost int
+
+	// IdleConnTimeout defines how long an idle connection is kept.
+	// 0 means 90s as the default of net/http.
+	IdleConnTimeout time.Duration
+
+	// DisableKeepAlives makes every request use a new connection.
+	DisableKeepAlives bool
+
+	// Assertions check every response, and the first error counts the request as a failure.
+	// nil means StatusSuccess, so that any non-2xx response fails.
+	Assertions []Assertion
+}
+
+const (
+	defaultDialTimeout         = 30 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultMaxIdleConnsPerHost = 100
+)
+
+// Requester implements otchkiss.Requester by sending the request of Config.
+// It is thread safe, so one Requester can be shared by all the concurrent requests.
+type Requester struct {
+	method     string
+	url        string
+	header     http.Header
+	host       string
+	body       []byte
+	bodyFunc   func(ctx context.Context, iteration int64) ([]byte, error)
+	assertions []Assertion
+
+	client    *http.Client
+	iteration atomic.Int64
+}
+
+// New returns Requester which sends the request of c.
+func New(c Config) (*Requester, error) {
+	if err := validate(c); err != nil {
+		return nil, err
+	}
+
+	method := c.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	assertions := c.Assertions
+	if assertions == nil {
+		assertions = []Assertion{StatusSuccess()}
+	}
+	maxIdle := c.MaxIdleConnsPerHost
+	if maxIdle == 0 {
+		maxIdle = c.MaxConnsPerHost
+	}
+	if maxIdle == 0 {
+		maxIdle = defaultMaxIdleConnsPerHost
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   orDefault(c.DialTimeout, defaultDialTimeout),
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   orDefault(c.TLSHandshakeTimeout, defaultTLSHandshakeTimeout),
+		ResponseHeaderTimeout: c.ResponseHeaderTimeout,
+		MaxIdleConns:          0, // Only limited per host.
+		MaxIdleConnsPerHost:   maxIdle,
+		MaxConnsPerHost:       c.MaxConnsPerHost,
+		IdleConnTimeout:       orDefault(c.IdleConnTimeout, defaultIdleConnTimeout),
+		DisableKeepAlives:     c.DisableKeepAlives,
+	}
+
+	return &Requester{
+		method:     method,
+		url:        c.URL,
+		header:     c.Header.Clone(),
+		host:       c.Header.Get("Host"),
+		body:       c.Body,
+		bodyFunc:   c.BodyFunc,
+		assertions: assertions,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   c.Timeout,
+		},
+	}, nil
+}
+
+func validate(c Config) error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("URL must begin with http:// or https://")
+	}
+	if u.Host == "" {
+		return errors.New("URL must have the host")
+	}
+	if !(c.Timeout >= 0*time.Second) {
+		return errors.New("timeout must be >= 0 sec")
+	}
+	if !(c.DialTimeout >= 0*time.Second) {
+		return errors.New("dial timeout must be >= 0 sec")
+	}
+	if !(c.TLSHandshakeTimeout >= 0*time.Second) {
+		return errors.New("TLS handshake timeout must be >= 0 sec")
+	}
+	if !(c.ResponseHeaderTimeout >= 0*time.Second) {
+		return errors.New("response header timeout must be >= 0 sec")
+	}
+	if !(c.IdleConnTimeout >= 0*time.Second) {
+		return errors.New("idle connection timeout must be >= 0 sec")
+	}
+	if !(c.MaxIdleConnsPerHost >= 0) {
+		return errors.New("max idle connections per host must be >= 0")
+	}
+	if !(c.MaxConnsPerHost >= 0) {
+		return errors.New("max connections per host must be >= 0")
+	}
+	for _, a := range c.Assertions {
+		if a == nil {
+			return errors.New("assertion must not be nil")
+		}
+	}
+	return nil
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+	return d
+}
+
+// Init does nothing, because the connections are made by the requests.
+func (r *Requester) Init() error {
+	return nil
+}
+
+// RequestOne sends the request, reads the whole response body and checks the response by the assertions.
+// The durations of the parts of the request are recorded by otchkiss.Timing as TimingDNS, TimingConnect, TimingTLS, TimingTTFB and TimingTransfer.
+func (r *Requester) RequestOne(ctx context.Context) error {
+	body := r.body
+	if r.bodyFunc != nil {
+		var err error
+		body, err = r.bodyFunc(ctx, r.iteration.Add(1)-1)
+		if err != nil {
+			return fmt.Errorf("generate body: %w", err)
+		}
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	tr := &tracer{}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, tr.clientTrace()), r.method, r.url, reader)
+	if err != nil {
+		return err
+	}
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	if r.host != "" {
+		req.Host = r.host
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		tr.record(ctx, time.Time{})
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The body is read to the end even when no assertion needs it, so that the connection can be reused.
+	buf := bufPool.Get().(*bytes.Buffer)
+	defer bufPool.Put(buf)
+	buf.Reset()
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		tr.record(ctx, time.Time{})
+		return fmt.Errorf("read body: %w", err)
+	}
+	tr.record(ctx, time.Now())
+
+	for _, a := range r.assertions {
+		if err := a(resp, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Terminate closes the idle connections.
+func (r *Requester) Terminate() error {
+	r.client.CloseIdleConnections()
+	return nil
+}
+
+var bufPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
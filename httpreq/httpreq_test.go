@@ -0,0 +1,367 @@
+package httpreq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ryo-yamaoka/otchkiss"
+	"github.com/ryo-yamaoka/otchkiss/setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		config    Config
+		wantError assert.ErrorAssertionFunc
+	}{
+		"ok": {
+			config:    Config{URL: "http://localhost:8080/path"},
+			wantError: assert.NoError,
+		},
+		"ok: https": {
+			config:    Config{Method: http.MethodPost, URL: "https://example.com", Timeout: time.Second, MaxConnsPerHost: 10},
+			wantError: assert.NoError,
+		},
+		"ng: no scheme": {
+			config:    Config{URL: "localhost:8080"},
+			wantError: assert.Error,
+		},
+		"ng: other scheme": {
+			config:    Config{URL: "ftp://localhost"},
+			wantError: assert.Error,
+		},
+		"ng: no host": {
+			config:    Config{URL: "http:///path"},
+			wantError: assert.Error,
+		},
+		"ng: negative timeout": {
+			config:    Config{URL: "http://localhost", Timeout: -1},
+			wantError: assert.Error,
+		},
+		"ng: negative dial timeout": {
+			config:    Config{URL: "http://localhost", DialTimeout: -1},
+			wantError: assert.Error,
+		},
+		"ng: negative max connections": {
+			config:    Config{URL: "http://localhost", MaxConnsPerHost: -1},
+			wantError: assert.Error,
+		},
+		"ng: nil assertion": {
+			config:    Config{URL: "http://localhost", Assertions: []Assertion{nil}},
+			wantError: assert.Error,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := New(tc.config)
+			tc.wantError(t, err)
+		})
+	}
+}
+
+// received is the request which the test server received.
+type received struct {
+	Method string
+	Path   string
+	Host   string
+	Header string
+	Body   string
+}
+
+func TestRequestOne(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		config     func(url string) Config
+		status     int
+		want       received
+		wantStatus int
+		wantError  error
+	}{
+		"GET": {
+			config: func(url string) Config {
+				return Config{URL: url + "/get"}
+			},
+			status: http.StatusOK,
+			want:   received{Method: http.MethodGet, Path: "/get"},
+		},
+		"POST with header and body": {
+			config: func(url string) Config {
+				return Config{
+					Method: http.MethodPost,
+					URL:    url + "/post",
+					Header: http.Header{"X-Test": {"value"}, "Host": {"example.com"}},
+					Body:   []byte(`{"id":1}`),
+				}
+			},
+			status: http.StatusCreated,
+			want:   received{Method: http.MethodPost, Path: "/post", Host: "example.com", Header: "value", Body: `{"id":1}`},
+		},
+		"non-2xx": {
+			config: func(url string) Config {
+				return Config{URL: url}
+			},
+			status:     http.StatusServiceUnavailable,
+			want:       received{Method: http.MethodGet, Path: "/"},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		"expected non-2xx": {
+			config: func(url string) Config {
+				return Config{URL: url, Assertions: []Assertion{Status(http.StatusNotFound)}}
+			},
+			status: http.StatusNotFound,
+			want:   received{Method: http.MethodGet, Path: "/"},
+		},
+		"bad body": {
+			config: func(url string) Config {
+				return Config{URL: url, Assertions: []Assertion{StatusSuccess(), BodyContains("ng")}}
+			},
+			status:    http.StatusOK,
+			want:      received{Method: http.MethodGet, Path: "/"},
+			wantError: &BodyError{Reason: `does not contain "ng"`},
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			var got received
+			var srv *httptest.Server
+			srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				body, _ := io.ReadAll(req.Body)
+				got = received{Method: req.Method, Path: req.URL.Path, Header: req.Header.Get("X-Test"), Body: string(body)}
+				if req.Host != srv.Listener.Addr().String() {
+					got.Host = req.Host // Only when it is overridden.
+				}
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, "ok")
+			}))
+			defer srv.Close()
+
+			r, err := New(tc.config(srv.URL))
+			require.NoError(t, err)
+			require.NoError(t, r.Init())
+			err = r.RequestOne(context.Background())
+			require.NoError(t, r.Terminate())
+
+			assert.Empty(t, cmp.Diff(tc.want, got))
+			switch {
+			case tc.wantStatus != 0:
+				var se *StatusError
+				require.ErrorAs(t, err, &se)
+				assert.Equal(t, tc.wantStatus, se.StatusCode)
+			case tc.wantError != nil:
+				assert.Equal(t, tc.wantError, err)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRequestOneBodyFunc(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, string(body))
+	}))
+	defer srv.Close()
+
+	r, err := New(Config{
+		Method: http.MethodPost,
+		URL:    srv.URL,
+		Body:   []byte("ignored"),
+		BodyFunc: func(_ context.Context, iteration int64) ([]byte, error) {
+			if iteration == 5 {
+				return nil, errors.New("no more")
+			}
+			return []byte(strconv.FormatInt(iteration, 10)), nil
+		},
+	})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var failed atomic.Int64
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := r.RequestOne(context.Background()); err != nil {
+				failed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Strings(bodies)
+	assert.Equal(t, []string{"0", "1", "2", "3", "4"}, bodies)
+	assert.Equal(t, int64(1), failed.Load())
+}
+
+func TestRequestOneTimeout(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	r, err := New(Config{URL: srv.URL, Timeout: 20 * time.Millisecond})
+	require.NoError(t, err)
+	err = r.RequestOne(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, "timeout", Classify(err))
+}
+
+func TestDisableKeepAlives(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		disable bool
+		want    int64
+	}{
+		"keep alive": {
+			disable: false,
+			want:    1,
+		},
+		"disabled": {
+			disable: true,
+			want:    3,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			var conns atomic.Int64
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+			srv.Config.ConnState = func(_ net.Conn, s http.ConnState) {
+				if s == http.StateNew {
+					conns.Add(1)
+				}
+			}
+			srv.Start()
+			defer srv.Close()
+
+			r, err := New(Config{URL: srv.URL, DisableKeepAlives: tc.disable})
+			require.NoError(t, err)
+			for i := 0; i < 3; i++ {
+				require.NoError(t, r.RequestOne(context.Background()))
+			}
+			assert.Equal(t, tc.want, conns.Load())
+		})
+	}
+}
+
+func TestOtchkiss(t *testing.T) {
+	t.Parallel()
+
+	var n atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if n.Add(1)%4 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	r, err := New(Config{URL: srv.URL})
+	require.NoError(t, err)
+	ot, err := otchkiss.FromConfig(r, &setting.Setting{MaxConcurrent: 1, Iterations: 20}, 20)
+	require.NoError(t, err)
+	ot.Result.SetErrorClassifier(Classify)
+	require.NoError(t, ot.Start(context.Background()))
+
+	assert.Equal(t, int64(15), ot.Result.Succeeded())
+	assert.Equal(t, int64(5), ot.Result.Failed())
+	groups := ot.Result.ErrorGroups()
+	require.Len(t, groups, 1)
+	assert.Equal(t, "status 500", groups[0].Key)
+}
+
+func TestRequestOneTimings(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		newServer func(http.Handler) *httptest.Server
+		hostname  bool
+		want      []string
+	}{
+		"http": {
+			newServer: httptest.NewServer,
+			want:      []string{TimingConnect, TimingTTFB, TimingTransfer},
+		},
+		"hostname": {
+			newServer: httptest.NewServer,
+			hostname:  true,
+			want:      []string{TimingDNS, TimingConnect, TimingTTFB, TimingTransfer},
+		},
+		"https": {
+			newServer: httptest.NewTLSServer,
+			want:      []string{TimingConnect, TimingTLS, TimingTTFB, TimingTransfer},
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			srv := tc.newServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				time.Sleep(20 * time.Millisecond)
+				fmt.Fprint(w, "ok")
+			}))
+			defer srv.Close()
+
+			u := srv.URL
+			if tc.hostname {
+				u = strings.Replace(u, "127.0.0.1", "localhost", 1)
+			}
+			r, err := New(Config{URL: u})
+			require.NoError(t, err)
+			r.client.Transport.(*http.Transport).TLSClientConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig
+			ot, err := otchkiss.FromConfig(r, &setting.Setting{MaxConcurrent: 1, Iterations: 3}, 3)
+			require.NoError(t, err)
+			require.NoError(t, ot.Start(context.Background()))
+			require.Equal(t, int64(3), ot.Result.Succeeded())
+
+			assert.Equal(t, tc.want, ot.Result.Timings())
+			assert.Equal(t, int64(1), ot.Result.Timing(TimingConnect).Succeeded(), "the connection must be reused")
+			assert.Equal(t, int64(3), ot.Result.Timing(TimingTTFB).Succeeded())
+			ttfb, err := ot.Result.Timing(TimingTTFB).PercentileLatency(0)
+			require.NoError(t, err)
+			assert.GreaterOrEqual(t, ttfb, 0.02)
+		})
+	}
+}
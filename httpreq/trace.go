@@ -0,0 +1,104 @@
+package httpreq
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss"
+)
+
+// The names of the timings which Requester records by otchkiss.Timing.
+// DNS, connect and TLS are recorded only when a new connection is made, so a reused connection has none of them.
+const (
+	// TimingDNS is the DNS lookup, it is not recorded for an IP address.
+	TimingDNS = "dns"
+
+	// TimingConnect is the TCP connection.
+	TimingConnect = "connect"
+
+	// TimingTLS is the TLS handshake.
+	TimingTLS = "tls"
+
+	// TimingTTFB is from when the request was written until the first byte of the response, which is the processing time of the server.
+	TimingTTFB = "ttfb"
+
+	// TimingTransfer is from the first byte of the response until the body is read to the end.
+	TimingTransfer = "transfer"
+)
+
+// tracer records when the events of a request happened.
+// The events may be reported from the other goroutines than RequestOne, for example when the addresses are dialed in parallel.
+type tracer struct {
+	mu           sync.Mutex
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wrote        time.Time
+	firstByte    time.Time
+}
+
+func (tr *tracer) clientTrace() *httptrace.ClientTrace {
+	// set sets *t to now unless it has been set, so that only the first of the repeated events counts.
+	set := func(t *time.Time) {
+		tr.mu.Lock()
+		defer tr.mu.Unlock()
+		if t.IsZero() {
+			*t = time.Now()
+		}
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { set(&tr.dnsStart) },
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			if info.Err == nil {
+				set(&tr.dnsDone)
+			}
+		},
+		ConnectStart: func(string, string) { set(&tr.connectStart) },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				set(&tr.connectDone)
+			}
+		},
+		TLSHandshakeStart: func() { set(&tr.tlsStart) },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				set(&tr.tlsDone)
+			}
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err == nil {
+				set(&tr.wrote)
+			}
+		},
+		GotFirstResponseByte: func() { set(&tr.firstByte) },
+	}
+}
+
+// record records the durations of the events which completed, end is when the body was read to the end or zero if it was not.
+func (tr *tracer) record(ctx context.Context, end time.Time) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	timings := []struct {
+		name       string
+		start, end time.Time
+	}{
+		{TimingDNS, tr.dnsStart, tr.dnsDone},
+		{TimingConnect, tr.connectStart, tr.connectDone},
+		{TimingTLS, tr.tlsStart, tr.tlsDone},
+		{TimingTTFB, tr.wrote, tr.firstByte},
+		{TimingTransfer, tr.firstByte, end},
+	}
+	for _, t := range timings {
+		if t.start.IsZero() || t.end.IsZero() {
+			continue
+		}
+		otchkiss.Timing(ctx, t.name, t.end.Sub(t.start))
+	}
+}
@@ -0,0 +1,110 @@
+package otchkiss
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartIterations(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		setting *setting.Setting
+	}{
+		"closed model": {
+			setting: &setting.Setting{MaxConcurrent: 4, Iterations: 50},
+		},
+		"closed model with warm up": {
+			setting: &setting.Setting{MaxConcurrent: 4, Iterations: 50, WarmUpTime: 100 * time.Millisecond},
+		},
+		"open model": {
+			setting: &setting.Setting{MaxConcurrent: 4, MaxRPS: 500, Iterations: 50, OpenModel: true},
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			ot, err := FromConfig(&testRequesterImpl{}, tc.setting, 50)
+			require.NoError(t, err)
+			require.NoError(t, ot.Start(context.Background()))
+			assert.Equal(t, int64(50), ot.Result.Succeeded())
+
+			report, err := ot.Report()
+			require.NoError(t, err)
+			assert.Contains(t, report, "* iterations:     50\n")
+		})
+	}
+}
+
+func TestStartIterationsPerVU(t *testing.T) {
+	t.Parallel()
+
+	vus := make([]*vuRequesterImpl, 0, 3)
+	ot, err := FromVirtualUsers(func(vu int) (Requester, error) {
+		r := &vuRequesterImpl{vu: vu}
+		vus = append(vus, r)
+		return r, nil
+	}, &setting.Setting{MaxConcurrent: 3, IterationsPerVU: 5}, 15)
+	require.NoError(t, err)
+	require.NoError(t, ot.Start(context.Background()))
+
+	assert.Equal(t, int64(15), ot.Result.Succeeded())
+	for _, vr := range vus {
+		assert.Equal(t, 5, vr.requests)
+	}
+}
+
+func TestStartIterationsMaxDuration(t *testing.T) {
+	t.Parallel()
+
+	ot, err := FromConfig(&slowRequesterImpl{latency: 10 * time.Millisecond}, &setting.Setting{
+		MaxConcurrent: 1,
+		Iterations:    1000,
+		MaxDuration:   200 * time.Millisecond,
+	}, 1000)
+	require.NoError(t, err)
+
+	begin := time.Now()
+	require.NoError(t, ot.Start(context.Background()))
+	assert.Less(t, time.Since(begin), 1*time.Second, "must end by the max duration")
+	assert.Less(t, ot.Result.Succeeded(), int64(1000))
+
+	// RPS is computed from the elapsed time, which is at most the max duration.
+	assert.LessOrEqual(t, ot.measuredDuration(), 300*time.Millisecond)
+}
+
+func TestStartIterationsInvalid(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]*setting.Setting{
+		"with stages": {
+			Iterations: 10,
+			Stages:     []setting.Stage{{Duration: 1 * time.Second}},
+		},
+		"per VU without virtual users": {
+			IterationsPerVU: 10,
+		},
+		"negative": {
+			Iterations: -1,
+		},
+	}
+
+	for tn, st := range testCases {
+		st := st
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			ot, err := FromConfig(&testRequesterImpl{}, st, 0)
+			require.NoError(t, err)
+			assert.Error(t, ot.Start(context.Background()))
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package otchkiss
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/arrival"
+	"github.com/ryo-yamaoka/otchkiss/sema"
+	"github.com/ryo-yamaoka/otchkiss/setting"
+)
+
+// stageInterval defines how often the limits are updated while following the stages.
+const stageInterval = 100 * time.Millisecond
+
+// loadControl holds the limits of the request which can be changed during the test, and paces the dispatch.
+type loadControl struct {
+	sem     *sema.Sema
+	stage   atomic.Int64
+	maxRPS  atomic.Int64
+	arrival arrival.Process
+
+	// iterations is the number of the measured requests dispatched so far, and maxIterations is its limit (0 means unlimited).
+	iterations    atomic.Int64
+	maxIterations int64
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newLoadControl(s *setting.Setting) *loadControl {
+	ap := s.Arrival
+	if ap == nil {
+		ap = arrival.Constant{}
+	}
+	lc := &loadControl{
+		sem:           sema.NewWeighted(int64(s.MaxConcurrent)),
+		arrival:       ap,
+		maxIterations: int64(s.Iterations),
+	}
+	lc.maxRPS.Store(int64(s.MaxRPS))
+	return lc
+}
+
+// interval returns the gap until the next dispatch at the current max RPS.
+// ok is false when the arrival process has no more request to dispatch.
+func (lc *loadControl) interval() (d time.Duration, ok bool) {
+	return lc.arrival.Next(float64(lc.maxRPS.Load()))
+}
+
+// wait blocks until the next dispatch is allowed.
+// Unlike the open model, the schedule is pushed back when the caller is late, so requests never burst to catch up.
+// It returns false when ctx is done or the arrival process has no more request to dispatch.
+func (lc *loadControl) wait(ctx context.Context) bool {
+	d, ok := lc.interval()
+	if !ok {
+		return false
+	}
+
+	lc.mu.Lock()
+	now := time.Now()
+	if lc.next.Before(now) {
+		lc.next = now
+	}
+	t := lc.next
+	lc.next = lc.next.Add(d)
+	lc.mu.Unlock()
+
+	return sleepUntil(ctx, t)
+}
+
+// takeIteration counts a measured request, and returns false when the iterations have already reached the limit.
+func (lc *loadControl) takeIteration() bool {
+	if lc.maxIterations == 0 {
+		return true
+	}
+	return lc.iterations.Add(1) <= lc.maxIterations
+}
+
+func (lc *loadControl) currentStage() int {
+	return int(lc.stage.Load())
+}
+
+// follow updates the limits along the stages of s until ctx is done.
+func (lc *loadControl) follow(ctx context.Context, s *setting.Setting) {
+	begin := time.Now()
+	apply := func() {
+		idx, maxRPS, maxConcurrent := s.StageAt(time.Since(begin))
+		lc.stage.Store(int64(idx))
+		lc.maxRPS.Store(int64(maxRPS))
+		if int64(maxConcurrent) != lc.sem.Limit() {
+			lc.sem.SetLimit(int64(maxConcurrent))
+		}
+	}
+
+	apply()
+	ticker := time.NewTicker(stageInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			apply()
+		}
+	}
+}
+
+// sleepUntil waits until t, and returns false if ctx is done before that.
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	d := time.Until(t)
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,169 @@
+package otchkiss
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+)
+
+// DefaultMetricsBuckets are the upper bounds of the latency histogram of Metrics in seconds, the same as the default of the Prometheus client.
+var DefaultMetricsBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics exposes the live metrics of the test in the Prometheus text exposition format while Start is running.
+// Set it to Otchkiss.Metrics and serve it by net/http, for example http.Handle("/metrics", m).
+// The counters accumulate over the runs of Start, and the latency histogram is of the measured requests only.
+type Metrics struct {
+	buckets []float64
+
+	// requests is the number of the completed requests by the phase and whether they failed.
+	requests [PhaseDone][2]atomic.Int64
+	inFlight atomic.Int64
+
+	// counts is the number of the measured requests in each bucket, and the last one is for +Inf.
+	mu     sync.Mutex
+	counts []int64
+	sum    float64
+
+	// result and lc are of the latest Start, they are nil before it.
+	result *result.Result
+	lc     *loadControl
+}
+
+// NewMetrics returns Metrics whose latency histogram has the upper bounds of buckets in seconds.
+// nil means DefaultMetricsBuckets.
+func NewMetrics(buckets []float64) (*Metrics, error) {
+	if buckets == nil {
+		buckets = DefaultMetricsBuckets
+	}
+	if len(buckets) == 0 {
+		return nil, errors.New("buckets must not be empty")
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		return nil, errors.New("buckets must be in ascending order")
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] == buckets[i-1] {
+			return nil, fmt.Errorf("duplicate bucket: %v", buckets[i])
+		}
+	}
+
+	return &Metrics{
+		buckets: append([]float64(nil), buckets...),
+		counts:  make([]int64, len(buckets)+1),
+	}, nil
+}
+
+// bind makes the gauges follow the run of Start.
+func (m *Metrics) bind(r *result.Result, lc *loadControl) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.result, m.lc = r, lc
+}
+
+func (m *Metrics) requestBegun() {
+	m.inFlight.Add(1)
+}
+
+func (m *Metrics) requestDone(ph Phase, measured bool, elapsed time.Duration, err error) {
+	m.inFlight.Add(-1)
+	var failed int
+	if err != nil {
+		failed = 1
+	}
+	m.requests[ph][failed].Add(1)
+	if !measured {
+		return
+	}
+
+	v := elapsed.Seconds()
+	i := sort.SearchFloat64s(m.buckets, v) // The first bucket whose upper bound is >= v.
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[i]++
+	m.sum += v
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	m.write(bw)
+	_ = bw.Flush() // The client has gone if it fails, so there is nobody to tell.
+}
+
+func (m *Metrics) write(w io.Writer) {
+	m.mu.Lock()
+	counts := append([]int64(nil), m.counts...)
+	sum, r, lc := m.sum, m.result, m.lc
+	m.mu.Unlock()
+
+	header(w, "otchkiss_requests_total", "counter", "Number of the completed requests.")
+	for _, ph := range []Phase{PhaseWarmUp, PhaseMeasure, PhaseCoolDown} {
+		for failed, res := range []string{"success", "failure"} {
+			fmt.Fprintf(w, "otchkiss_requests_total{phase=%q,result=%q} %d\n", metricsPhase(ph), res, m.requests[ph][failed].Load())
+		}
+	}
+
+	header(w, "otchkiss_in_flight", "gauge", "Number of the requests running now.")
+	fmt.Fprintf(w, "otchkiss_in_flight %d\n", m.inFlight.Load())
+
+	var dropped, late int64
+	var targetRPS, achievedRPS float64
+	if r != nil {
+		dropped, late = r.Dropped(), r.Late()
+		if d := r.Elapsed(); d > 0 {
+			achievedRPS = float64(r.Succeeded()+r.Failed()) / d.Seconds()
+		}
+	}
+	if lc != nil {
+		targetRPS = float64(lc.maxRPS.Load())
+	}
+	header(w, "otchkiss_dropped_total", "counter", "Number of the measured requests dropped because the backlog was full.")
+	fmt.Fprintf(w, "otchkiss_dropped_total %d\n", dropped)
+	header(w, "otchkiss_late_total", "counter", "Number of the measured requests started later than scheduled.")
+	fmt.Fprintf(w, "otchkiss_late_total %d\n", late)
+	header(w, "otchkiss_target_rps", "gauge", "Current max requests per second, 0 means unlimited.")
+	fmt.Fprintf(w, "otchkiss_target_rps %s\n", formatFloat(targetRPS))
+	header(w, "otchkiss_achieved_rps", "gauge", "Measured requests per second over the measurement so far.")
+	fmt.Fprintf(w, "otchkiss_achieved_rps %s\n", formatFloat(achievedRPS))
+
+	header(w, "otchkiss_request_duration_seconds", "histogram", "Latency of the measured requests.")
+	var cumulative int64
+	for i, b := range m.buckets {
+		cumulative += counts[i]
+		fmt.Fprintf(w, "otchkiss_request_duration_seconds_bucket{le=%q} %d\n", formatFloat(b), cumulative)
+	}
+	cumulative += counts[len(m.buckets)]
+	fmt.Fprintf(w, "otchkiss_request_duration_seconds_bucket{le=\"+Inf\"} %d\n", cumulative)
+	fmt.Fprintf(w, "otchkiss_request_duration_seconds_sum %s\n", formatFloat(sum))
+	fmt.Fprintf(w, "otchkiss_request_duration_seconds_count %d\n", cumulative)
+}
+
+func header(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// metricsPhase returns the label value of the phase.
+func metricsPhase(ph Phase) string {
+	switch ph {
+	case PhaseWarmUp:
+		return "warm_up"
+	case PhaseCoolDown:
+		return "cool_down"
+	default:
+		return "measure"
+	}
+}
@@ -0,0 +1,162 @@
+package otchkiss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/ryo-yamaoka/otchkiss/setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMetrics(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		buckets   []float64
+		wantError assert.ErrorAssertionFunc
+	}{
+		"default": {
+			buckets:   nil,
+			wantError: assert.NoError,
+		},
+		"ok": {
+			buckets:   []float64{0.1, 1},
+			wantError: assert.NoError,
+		},
+		"ng: empty": {
+			buckets:   []float64{},
+			wantError: assert.Error,
+		},
+		"ng: descending": {
+			buckets:   []float64{1, 0.1},
+			wantError: assert.Error,
+		},
+		"ng: duplicate": {
+			buckets:   []float64{0.1, 0.1},
+			wantError: assert.Error,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewMetrics(tc.buckets)
+			tc.wantError(t, err)
+		})
+	}
+}
+
+func TestMetricsServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	m, err := NewMetrics([]float64{0.1, 1})
+	require.NoError(t, err)
+
+	r, err := result.New()
+	require.NoError(t, err)
+	r.AppendSuccess(0.05)
+	r.AppendSuccess(0.5)
+	r.AppendFail(2, errors.New("err"))
+	r.AddDropped()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.ExtendWindow(base, base.Add(2*time.Second))
+	lc := newLoadControl(&setting.Setting{MaxRPS: 10})
+	m.bind(r, lc)
+
+	for i := 0; i < 5; i++ {
+		m.requestBegun()
+	}
+	m.requestDone(PhaseWarmUp, false, 10*time.Millisecond, nil)
+	m.requestDone(PhaseMeasure, true, 50*time.Millisecond, nil)
+	m.requestDone(PhaseMeasure, true, 500*time.Millisecond, nil)
+	m.requestDone(PhaseMeasure, true, 2*time.Second, errors.New("err"))
+
+	srv := httptest.NewServer(m)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", resp.Header.Get("Content-Type"))
+	want := `# HELP otchkiss_requests_total Number of the completed requests.
+# TYPE otchkiss_requests_total counter
+otchkiss_requests_total{phase="warm_up",result="success"} 1
+otchkiss_requests_total{phase="warm_up",result="failure"} 0
+otchkiss_requests_total{phase="measure",result="success"} 2
+otchkiss_requests_total{phase="measure",result="failure"} 1
+otchkiss_requests_total{phase="cool_down",result="success"} 0
+otchkiss_requests_total{phase="cool_down",result="failure"} 0
+# HELP otchkiss_in_flight Number of the requests running now.
+# TYPE otchkiss_in_flight gauge
+otchkiss_in_flight 1
+# HELP otchkiss_dropped_total Number of the measured requests dropped because the backlog was full.
+# TYPE otchkiss_dropped_total counter
+otchkiss_dropped_total 1
+# HELP otchkiss_late_total Number of the measured requests started later than scheduled.
+# TYPE otchkiss_late_total counter
+otchkiss_late_total 0
+# HELP otchkiss_target_rps Current max requests per second, 0 means unlimited.
+# TYPE otchkiss_target_rps gauge
+otchkiss_target_rps 10
+# HELP otchkiss_achieved_rps Measured requests per second over the measurement so far.
+# TYPE otchkiss_achieved_rps gauge
+otchkiss_achieved_rps 1.5
+# HELP otchkiss_request_duration_seconds Latency of the measured requests.
+# TYPE otchkiss_request_duration_seconds histogram
+otchkiss_request_duration_seconds_bucket{le="0.1"} 1
+otchkiss_request_duration_seconds_bucket{le="1"} 2
+otchkiss_request_duration_seconds_bucket{le="+Inf"} 3
+otchkiss_request_duration_seconds_sum 2.55
+otchkiss_request_duration_seconds_count 3
+`
+	assert.Empty(t, cmp.Diff(want, string(body)))
+}
+
+func TestStartMetrics(t *testing.T) {
+	t.Parallel()
+
+	m, err := NewMetrics(nil)
+	require.NoError(t, err)
+	srv := httptest.NewServer(m)
+	defer srv.Close()
+
+	pr := &phaseRequesterImpl{latency: 5 * time.Millisecond}
+	ot, err := FromConfig(pr, &setting.Setting{MaxConcurrent: 2, WarmUpRequests: 10, Iterations: 50}, 50)
+	require.NoError(t, err)
+	ot.Metrics = m
+
+	done := make(chan error)
+	go func() {
+		done <- ot.Start(context.Background())
+	}()
+	scrape := func() string {
+		resp, err := http.Get(srv.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+	assert.Contains(t, scrape(), "otchkiss_in_flight ", "scraped while running")
+	require.NoError(t, <-done)
+
+	body := scrape()
+	assert.Contains(t, body, fmt.Sprintf("otchkiss_requests_total{phase=\"measure\",result=\"success\"} %d\n", ot.Result.Succeeded()))
+	assert.Contains(t, body, "otchkiss_requests_total{phase=\"warm_up\",result=\"success\"} 10\n")
+	assert.Contains(t, body, "otchkiss_request_duration_seconds_count 50\n")
+	assert.Contains(t, body, "otchkiss_in_flight 0\n")
+}
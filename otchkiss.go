@@ -1,20 +1,15 @@
 package otchkiss
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"sync"
-	"text/template"
 	"time"
 
 	"github.com/ryo-yamaoka/otchkiss/result"
-	"github.com/ryo-yamaoka/otchkiss/sema"
 	"github.com/ryo-yamaoka/otchkiss/setting"
-
-	humanize "github.com/dustin/go-humanize"
-	"go.uber.org/ratelimit"
+	"github.com/ryo-yamaoka/otchkiss/threshold"
 )
 
 // Requester defines the behavior of the request that Otchkiss performs.
@@ -36,19 +31,52 @@ type Requester interface {
 
 type Otchkiss struct {
 	Requester Requester
-	Setting   *setting.Setting
-	Result    *result.Result
+
+	// Scenarios are run instead of Requester when they are specified.
+	Scenarios []Scenario
+
+	// Factory makes the Requester of each virtual user instead of Requester when it is specified.
+	Factory RequesterFactory
+
+	Setting *setting.Setting
+	Result  *result.Result
+
+	// Thresholds are the conditions which the Result must satisfy, they are evaluated by Verdict and shown in the report.
+	Thresholds []threshold.Threshold
+
+	// AbortConditions are checked during the measurement, and Start returns *ErrAborted when one of them fails.
+	AbortConditions []AbortCondition
+
+	// Progress receives the progress of the test every ProgressInterval while Start is running, and once more when the requests end.
+	// For example, NewTerminalProgress(os.Stderr) shows it in a single line.
+	Progress ProgressSink
+
+	// ProgressInterval defines how often Progress receives the progress.
+	// 0 means 1s.
+	ProgressInterval time.Duration
+
+	// Metrics exposes the live metrics of the test in the Prometheus text exposition format when it is specified.
+	Metrics *Metrics
+
+	// aborter monitors AbortConditions while Start is running, it is nil when they are not specified.
+	aborter *abortMonitor
+
+	// progress reports the progress to Progress while Start is running, it is nil when Progress is not specified.
+	progress *progressMonitor
 }
 
 // New returns Otchkiss instance with default setting.
-// By default, the following three command line arguments are parsed and set.
+// By default, the following command line arguments are parsed and set.
 //
 //	-p: Specify the number of parallels executions. 0 means unlimited (default: 1, it's not concurrently)
 //	-d: Running duration, ex: 300s or 5m etc... (default: 5s)
 //	-w: Exclude from results for a given time after startup, ex: 300s or 5m etc... (default: 5s)
 //	-r: Specify the max request per second. 0 means unlimited (default: 1)
+//	-n: Specify the number of requests to measure instead of -d, which then caps the duration if specified (default: 0, use -d)
 //
 // Note: -p or -r, whichever is smaller blocks the request.
+//
+// The result records latencies in the histogram, so its memory does not grow with the number of requests.
 func New(requester Requester) (*Otchkiss, error) {
 	s, err := setting.FromDefaultFlag()
 	if err != nil {
@@ -59,201 +87,401 @@ func New(requester Requester) (*Otchkiss, error) {
 		return nil, err
 	}
 
-	return new(requester, s, r)
+	return newOtchkiss(requester, nil, s, r)
 }
 
 // FromConfig returns Otchkiss instance by user specified setting.
-// Too large values for resultCapacity may cause OOM (FYI: default value is 100M).
+// The result keeps every latency as is, and resultCapacity is preallocated for them.
+// Too large values for resultCapacity may cause OOM.
 func FromConfig(requester Requester, setting *setting.Setting, resultCapacity int) (*Otchkiss, error) {
 	r, err := result.WithCapacity(resultCapacity)
 	if err != nil {
 		return nil, err
 	}
-	return new(requester, setting, r)
+	return newOtchkiss(requester, nil, setting, r)
+}
+
+// FromConfigWithResult returns Otchkiss instance by user specified setting and result.
+// For example, pass result.WithHistogram() to record latencies in the histogram of the specific precision.
+func FromConfigWithResult(requester Requester, setting *setting.Setting, r *result.Result) (*Otchkiss, error) {
+	if r == nil {
+		return nil, errors.New("nil result")
+	}
+	return newOtchkiss(requester, nil, setting, r)
 }
 
-func new(requester Requester, setting *setting.Setting, r *result.Result) (*Otchkiss, error) {
-	if requester == nil {
+func newOtchkiss(requester Requester, scenarios []Scenario, setting *setting.Setting, r *result.Result) (*Otchkiss, error) {
+	if requester == nil && len(scenarios) == 0 {
 		return nil, errors.New("nil requester")
 	}
+	if err := validateScenarios(scenarios); err != nil {
+		return nil, err
+	}
 	if setting == nil {
 		return nil, errors.New("nil setting")
 	}
 
 	return &Otchkiss{
 		Requester: requester,
+		Scenarios: scenarios,
 		Setting:   setting,
 		Result:    r,
 	}, nil
 }
 
 // Start run Otchkiss load testing, and the test follows these steps.
-//  1. Run Init()
+//  1. Run Init() (of each scenario or virtual user if Scenarios or Factory is specified)
 //  2. Start RequestOne() repeatedly as warm up (it will NOT count as Result)
-//  3. Start RequestOne() repeatedly as actual test (it will count as Result)
-//  4. End RequestOne() execute and run Terminate()
+//     for Setting.WarmUpTime, Setting.WarmUpRequests or until Setting.WarmUpStability is met
+//  3. Start RequestOne() repeatedly as actual test (it will count as Result), following Setting.Stages if specified,
+//     until the duration passes or Setting.Iterations is reached
+//  4. Continue RequestOne() as cool down for Setting.CoolDownTime (it will NOT count as Result)
+//  5. End RequestOne() execute and run Terminate()
+//
+// When one of AbortConditions fails during the measurement, the test is canceled, the requests in flight are waited for,
+// and Start returns *ErrAborted after Terminate().
 func (ot *Otchkiss) Start(ctx context.Context) error {
-	if err := ot.Requester.Init(); err != nil {
+	if err := validate(ot.Setting); err != nil {
+		return fmt.Errorf("invalid setting: %w", err)
+	}
+	if err := validateScenarios(ot.Scenarios); err != nil {
+		return fmt.Errorf("invalid scenario: %w", err)
+	}
+
+	for _, th := range ot.Thresholds {
+		if err := th.Validate(); err != nil {
+			return fmt.Errorf("invalid threshold %s: %w", th, err)
+		}
+	}
+
+	for _, c := range ot.AbortConditions {
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("invalid abort condition %s: %w", c, err)
+		}
+	}
+
+	if !(ot.ProgressInterval >= 0) {
+		return errors.New("invalid progress interval: must be >= 0 sec")
+	}
+
+	if ot.Setting.IterationsPerVU != 0 && ot.Factory == nil {
+		return errors.New("invalid setting: iterations per VU requires virtual users")
+	}
+
+	var scenarios []Scenario
+	var requesters []Requester
+	if ot.Factory != nil {
+		if err := ot.validateVirtualUsers(); err != nil {
+			return fmt.Errorf("invalid virtual users: %w", err)
+		}
+		vus, err := ot.makeVirtualUsers()
+		if err != nil {
+			return fmt.Errorf("failed to create requester: %w", err)
+		}
+		requesters = vus
+	} else {
+		scenarios = ot.scenarios()
+		requesters = requestersOf(scenarios)
+	}
+	if err := initRequesters(requesters); err != nil {
 		return fmt.Errorf("failed to initialize requester: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, ot.Setting.RunDuration+ot.Setting.WarmUpTime)
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	warmUp := make(chan struct{})
-	go func() {
-		time.Sleep(ot.Setting.WarmUpTime)
-		close(warmUp)
-	}()
+	lc := newLoadControl(ot.Setting)
+	ph := newPhase(ot.Setting)
+	go ph.run(ctx, cancel, lc)
+
+	ot.aborter = nil
+	if len(ot.AbortConditions) != 0 {
+		ot.aborter = newAbortMonitor(ot.AbortConditions, ot.Result)
+		go ot.aborter.run(ctx, cancel)
+	}
+
+	if ot.Metrics != nil {
+		ot.Metrics.bind(ot.Result, lc)
+	}
+
+	ot.progress = nil
+	if ot.Progress != nil {
+		ot.progress = newProgressMonitor(ot.Progress, ot.ProgressInterval, ot.Setting, ot.Result, ph)
+		go ot.progress.run()
+	}
+
+	if ot.Factory != nil {
+		var wg sync.WaitGroup
+		ot.runVirtualUsers(ctx, lc, requesters, ph, &wg)
+		wg.Wait()
+	} else {
+		p := newPool(ot.Setting, ot.runJob(ctx, lc, ph))
+		sp := newScenarioPicker(scenarios)
+		if ot.Setting.OpenModel {
+			ot.runOpen(ctx, lc, sp, ph, p)
+		} else {
+			ot.runClosed(ctx, lc, sp, ph, p)
+		}
+		p.close()
+	}
+	if ot.progress != nil {
+		ot.progress.close()
+	}
+
+	err := terminateRequesters(requesters)
+	if ot.aborter != nil {
+		if aerr := ot.aborter.err(); aerr != nil && err != nil {
+			return errors.Join(aerr, err)
+		} else if aerr != nil {
+			return aerr
+		}
+	}
+	return err
+}
+
+// Verdict evaluates the Thresholds against the Result, so call it after Start.
+func (ot *Otchkiss) Verdict() threshold.Verdict {
+	return threshold.Evaluate(ot.Result, ot.measuredDuration(), ot.Thresholds)
+}
+
+// initRequesters runs Init of each requester, and terminates already initialized ones if any of them fails.
+func initRequesters(requesters []Requester) error {
+	for i, r := range requesters {
+		if err := r.Init(); err != nil {
+			return errors.Join(err, terminateRequesters(requesters[:i]))
+		}
+	}
+	return nil
+}
 
-	sem := sema.NewWeighted(int64(ot.Setting.MaxConcurrent))
-	rl := ratelimit.NewUnlimited()
-	maxRPS := ot.Setting.MaxRPS
-	if maxRPS != 0 {
-		rl = ratelimit.New(maxRPS, ratelimit.Per(1*time.Second))
+func terminateRequesters(requesters []Requester) error {
+	var errs []error
+	for _, r := range requesters {
+		if err := r.Terminate(); err != nil {
+			errs = append(errs, err)
+		}
 	}
+	return errors.Join(errs...)
+}
 
-	var wg sync.WaitGroup
+// runClosed queues the next RequestOne after the concurrency and the arrival process allow it.
+// The dispatch waits when the backlog is full, so the requests never pile up more than it.
+func (ot *Otchkiss) runClosed(ctx context.Context, lc *loadControl, sp *scenarioPicker, ph *phase, p *pool) {
 	for {
 		if ctx.Err() != nil {
-			break
+			return
 		}
-		if err := sem.Acquire(ctx, 1); err != nil {
-			break
+		if err := lc.sem.Acquire(ctx, 1); err != nil {
+			return
 		}
-		rl.Take()
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			start := time.Now()
-			err := ot.Requester.RequestOne(ctx)
-			elapsed := time.Since(start) // Do this before error handling to obtain the most accurate time possible.
-			sem.Release(1)               // Do this before error handling to release semaphore as soon as possible.
-
-			select {
-			case <-warmUp:
-				if err != nil {
-					ot.Result.AppendFail(elapsed.Seconds(), err)
-					return
-				}
-				ot.Result.AppendSuccess(elapsed.Seconds())
-				return
-			default:
+		if !lc.wait(ctx) {
+			lc.sem.Release(1)
+			return
+		}
+		measured := ph.dispatch()
+		if measured && !lc.takeIteration() {
+			if !ph.finishIterations() {
+				lc.sem.Release(1)
 				return
 			}
-		}()
+			measured = false
+		}
+		if !p.submit(ctx, job{scenario: sp.pick(), stage: lc.currentStage(), scheduled: time.Now(), measured: measured, phase: ph.of(measured)}) {
+			lc.sem.Release(1)
+			return
+		}
 	}
+}
+
+// runOpen queues RequestOne at the intended start times given by the arrival process regardless of whether the previous requests have completed.
+// The latency from the intended start is recorded as the corrected latency, so it includes the time waited in the backlog and for the concurrency.
+// When the backlog is full, the request is dropped instead of waiting, so that the schedule is kept.
+func (ot *Otchkiss) runOpen(ctx context.Context, lc *loadControl, sp *scenarioPicker, ph *phase, p *pool) {
+	next := time.Now()
+	for {
+		if !sleepUntil(ctx, next) {
+			return
+		}
+		intended := next
+		d, ok := lc.interval()
+		if !ok {
+			return
+		}
+		next = next.Add(d)
 
-	wg.Wait()
-	return ot.Requester.Terminate()
+		// A dropped request also counts as an iteration, so the length of the schedule does not depend on the target.
+		measured := ph.dispatch()
+		if measured && !lc.takeIteration() {
+			if !ph.finishIterations() {
+				return
+			}
+			measured = false
+		}
+		if !p.trySubmit(job{scenario: sp.pick(), stage: lc.currentStage(), scheduled: intended, measured: measured, phase: ph.of(measured)}) && measured {
+			ot.Result.AddDropped()
+		}
+	}
 }
 
-type ReportParams struct {
-	TotalRequests string
-	Succeeded     string
-	Failed        string
-	WarmUpTime    string
-	Duration      string
-	MaxConcurrent int
-	MaxRPS        int
-	ErrorRate     string
-	RPS           string
-	MaxLatency    string
-	MinLatency    string
-	AvgLatency    string
-	MedLatency    string
-	Latency99p    string
-	Latency90p    string
-	Histogram     string
+// runJob returns the function which the workers of the pool call for each job.
+// In the closed model, the semaphore has been acquired by the dispatch, otherwise the worker acquires it.
+func (ot *Otchkiss) runJob(ctx context.Context, lc *loadControl, ph *phase) func(job) {
+	return func(j job) {
+		if time.Since(j.scheduled) > lateThreshold && j.measured {
+			ot.Result.AddLate()
+		}
+		if ot.Setting.OpenModel {
+			if err := lc.sem.Acquire(ctx, 1); err != nil {
+				return
+			}
+		} else if ctx.Err() != nil {
+			lc.sem.Release(1)
+			return
+		}
+
+		rctx, rec := withRecorder(ctx)
+		ot.requestBegun()
+		start := time.Now()
+		err := j.scenario.Requester.RequestOne(rctx)
+		end := time.Now() // Do this before error handling to obtain the most accurate time possible.
+		lc.sem.Release(1) // Do this before error handling to release semaphore as soon as possible.
+		ot.requestDone(j.phase, j.measured, end.Sub(start), err)
+
+		if j.measured {
+			ot.record(sample{stage: j.stage, scenario: j.scenario.Name, dispatched: j.scheduled, completed: end, elapsed: end.Sub(start), corrected: end.Sub(j.scheduled), tags: rec.recordedTags(), timings: rec.recordedTimings(), err: err})
+		} else {
+			ph.observe(end.Sub(start))
+		}
+	}
 }
 
-// Report outputs result of Otchkiss testing by default template.
-func (ot *Otchkiss) Report() (string, error) {
-	return ot.report(defaultReportTemplate)
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
 }
 
-// TemplateReport outputs result of Otchkiss testing by user template.
-// See `template.go` for a sample.
-func (ot *Otchkiss) TemplateReport(template string) (string, error) {
-	return ot.report(template)
+// requestBegun tells the monitors that RequestOne is about to run.
+func (ot *Otchkiss) requestBegun() {
+	if ot.progress != nil {
+		ot.progress.requestBegun()
+	}
+	if ot.Metrics != nil {
+		ot.Metrics.requestBegun()
+	}
 }
 
-func (ot *Otchkiss) report(templ string) (string, error) {
-	if templ == "" {
-		return "", errors.New("empty template")
+// requestDone tells the monitors that RequestOne dispatched in p completed, whether it is measured or not.
+func (ot *Otchkiss) requestDone(p Phase, measured bool, elapsed time.Duration, err error) {
+	if ot.progress != nil {
+		ot.progress.requestDone(elapsed, err)
 	}
+	if ot.Metrics != nil {
+		ot.Metrics.requestDone(p, measured, elapsed, err)
+	}
+}
 
-	rp, err := ot.reportParam()
-	if err != nil {
-		return "", fmt.Errorf("failed to generate report parameters: %w", err)
+// sample is an outcome of RequestOne.
+type sample struct {
+	stage    int
+	scenario string
+	elapsed  time.Duration
+
+	// dispatched and completed are when the request was dispatched and completed, they make the measurement window.
+	dispatched time.Time
+	completed  time.Time
+
+	// corrected is the latency from the intended start, it is recorded only in the open model.
+	corrected time.Duration
+
+	tags    []result.Tag
+	timings []timing
+	err     error
+}
+
+// record appends the outcome of RequestOne to the Result.
+func (ot *Otchkiss) record(s sample) {
+	ot.Result.ExtendWindow(s.dispatched, s.completed)
+	if ot.aborter != nil {
+		ot.aborter.add(s)
 	}
 
-	tmpl, err := template.New("").Parse(templ)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse report format: %w", err)
+	results := []*result.Result{ot.Result}
+	if len(ot.Setting.Stages) != 0 {
+		results = append(results, ot.Result.Stage(s.stage))
 	}
-	buf := bytes.NewBuffer(nil)
-	if err := tmpl.Execute(buf, rp); err != nil {
-		return "", fmt.Errorf("failed to rendering report: %w", err)
+	if len(ot.Scenarios) != 0 {
+		results = append(results, ot.Result.Scenario(s.scenario))
+	}
+	for _, t := range s.tags {
+		results = append(results, ot.Result.Tagged(t))
 	}
 
-	return buf.String(), nil
+	for _, r := range results {
+		appendSample(r, s.elapsed, s.err)
+		if ot.Setting.OpenModel {
+			appendSample(r.Corrected(), s.corrected, s.err)
+		}
+	}
+	for _, t := range s.timings {
+		ot.Result.Timing(t.name).AppendSuccess(t.d.Seconds())
+	}
 }
 
-func (ot *Otchkiss) reportParam() (*ReportParams, error) {
-	succeeded := ot.Result.Succeeded()
-	failed := ot.Result.Failed()
-	total := succeeded + failed
-
-	max, err := ot.Result.PercentileLatency(100)
+func appendSample(r *result.Result, elapsed time.Duration, err error) {
 	if err != nil {
-		return nil, fmt.Errorf("failed to get max latency: %w", err)
+		r.AppendFail(elapsed.Seconds(), err)
+		return
 	}
-	min, err := ot.Result.PercentileLatency(0)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get min latency: %w", err)
+	r.AppendSuccess(elapsed.Seconds())
+}
+
+func validate(s *setting.Setting) error {
+	if err := setting.ValidateStages(s.Stages); err != nil {
+		return err
 	}
-	p99, err := ot.Result.PercentileLatency(99)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get 99p latency: %w", err)
+	if s.OpenModel {
+		if s.MaxRPS == 0 {
+			return errors.New("open model requires max RPS > 0")
+		}
+		for _, st := range s.Stages {
+			if st.TargetRPS == 0 {
+				return errors.New("open model requires stage target RPS > 0")
+			}
+		}
 	}
-	p90, err := ot.Result.PercentileLatency(90)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get 90p latency: %w", err)
+	if !(s.Iterations >= 0) {
+		return errors.New("iterations must be >= 0")
 	}
-	p50, err := ot.Result.PercentileLatency(50)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get 50p latency: %w", err)
+	if !(s.IterationsPerVU >= 0) {
+		return errors.New("iterations per VU must be >= 0")
 	}
-	hist, err := ot.Result.Histogram(9, 25)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate histogram: %w", err)
-	}
-
-	ll := ot.Result.Latencies()
-	var avg float64
-	for _, l := range ll {
-		avg += l
-	}
-	avg = avg / float64(len(ll))
-
-	return &ReportParams{
-		TotalRequests: humanize.Comma(total),
-		Succeeded:     humanize.Comma(succeeded),
-		Failed:        humanize.Comma(failed),
-		WarmUpTime:    ot.Setting.WarmUpTime.String(),
-		Duration:      ot.Setting.RunDuration.String(),
-		MaxConcurrent: ot.Setting.MaxConcurrent,
-		MaxRPS:        ot.Setting.MaxRPS,
-		ErrorRate:     humanize.CommafWithDigits(float64(failed)/float64(total)*100, 1),
-		RPS:           humanize.CommafWithDigits(float64(total)/ot.Setting.RunDuration.Seconds(), 1),
-		MaxLatency:    humanize.CommafWithDigits(max*1000, 1),
-		MinLatency:    humanize.CommafWithDigits(min*1000, 1),
-		AvgLatency:    humanize.CommafWithDigits(avg*1000, 1),
-		MedLatency:    humanize.CommafWithDigits(p50*1000, 1),
-		Latency99p:    humanize.CommafWithDigits(p99*1000, 1),
-		Latency90p:    humanize.CommafWithDigits(p90*1000, 1),
-		Histogram:     hist,
-	}, nil
+	if !(s.MaxDuration >= 0) {
+		return errors.New("max duration must be >= 0 sec")
+	}
+	if !(s.WarmUpRequests >= 0) {
+		return errors.New("warm up requests must be >= 0")
+	}
+	if err := setting.ValidateStability(s.WarmUpStability); err != nil {
+		return err
+	}
+	if s.WarmUpRequests != 0 && s.WarmUpStability != nil {
+		return errors.New("warm up requests cannot be combined with warm up stability")
+	}
+	if !(s.CoolDownTime >= 0) {
+		return errors.New("cool down time must be >= 0 sec")
+	}
+	if s.Iterative() && len(s.Stages) != 0 {
+		return errors.New("iterations cannot be combined with stages")
+	}
+	if !(s.Workers >= 0) {
+		return errors.New("workers must be >= 0")
+	}
+	if !(s.MaxBacklog >= 0) {
+		return errors.New("max backlog must be >= 0")
+	}
+	return nil
 }
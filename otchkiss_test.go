@@ -2,12 +2,15 @@ package otchkiss
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
-	"github.com/google/go-cmp/cmp"
+	"github.com/ryo-yamaoka/otchkiss/arrival"
 	"github.com/ryo-yamaoka/otchkiss/result"
 	"github.com/ryo-yamaoka/otchkiss/setting"
 	"github.com/stretchr/testify/assert"
@@ -161,68 +164,294 @@ func TestFromConfig(t *testing.T) {
 	}
 }
 
-func TestReport(t *testing.T) {
+func TestStartStages(t *testing.T) {
 	t.Parallel()
 
-	testCases := map[string]struct {
-		setting    *setting.Setting
-		templ      string
-		wantReport string
-		wantError  assert.ErrorAssertionFunc
-	}{
-		"default": {
-			setting: &setting.Setting{
-				MaxConcurrent: 1,
-				MaxRPS:        1,
-				RunDuration:   2 * time.Second,
-				WarmUpTime:    3 * time.Second,
-			},
-			templ:      defaultReportTemplate,
-			wantReport: "\n[Setting]\n* warm up time:   3s\n* duration:       2s\n* max concurrent: 1\n* max RPS:        1\n\n[Request]\n* total:      3\n* succeeded:  2\n* failed:     1\n* error rate: 33.3 %\n* RPS:        1.5\n\n[Latency]\n* max: 3,000 ms\n* min: 1,000 ms\n* avg: 2,000 ms\n* med: 1,000 ms\n* 99th percentile: 2,000 ms\n* 90th percentile: 2,000 ms\n\n[Histogram]\n1s-1.222222222s            33.3%  █████████████████████████▏  1\n1.222222222s-1.444444444s  0%     ▏                           \n1.444444444s-1.666666666s  0%     ▏                           \n1.666666666s-1.888888888s  0%     ▏                           \n1.888888888s-2.111111111s  33.3%  █████████████████████████▏  1\n2.111111111s-2.333333333s  0%     ▏                           \n2.333333333s-2.555555555s  0%     ▏                           \n2.555555555s-2.777777777s  0%     ▏                           \n2.777777777s-3s            33.3%  █████████████████████████▏  1\n\n",
-			wantError:  assert.NoError,
+	ot, err := FromConfig(&testRequesterImpl{}, &setting.Setting{
+		MaxConcurrent: 1,
+		MaxRPS:        10,
+		Stages: []setting.Stage{
+			{Duration: 300 * time.Millisecond, TargetRPS: 10, TargetConcurrent: 1},
+			{Duration: 300 * time.Millisecond, TargetRPS: 100, TargetConcurrent: 2},
 		},
-		"user format": {
-			setting: &setting.Setting{
-				WarmUpTime: 3 * time.Second,
-			},
-			templ:      "{{.WarmUpTime}}",
-			wantReport: "3s",
-			wantError:  assert.NoError,
+	}, 100)
+	require.NoError(t, err)
+
+	require.NoError(t, ot.Start(context.Background()))
+
+	stages := ot.Result.Stages()
+	require.Len(t, stages, 2)
+	assert.Positive(t, stages[0].Succeeded())
+	assert.Greater(t, stages[1].Succeeded(), stages[0].Succeeded()*2)
+	assert.Equal(t, ot.Result.Succeeded(), stages[0].Succeeded()+stages[1].Succeeded())
+}
+
+type slowRequesterImpl struct {
+	testRequesterImpl
+	latency time.Duration
+}
+
+func (sr *slowRequesterImpl) RequestOne(_ context.Context) error {
+	time.Sleep(sr.latency)
+	return nil
+}
+
+func TestStartOpenModel(t *testing.T) {
+	t.Parallel()
+
+	ot, err := FromConfig(&slowRequesterImpl{latency: 50 * time.Millisecond}, &setting.Setting{
+		MaxConcurrent: 1,
+		MaxRPS:        40,
+		RunDuration:   500 * time.Millisecond,
+		OpenModel:     true,
+	}, 100)
+	require.NoError(t, err)
+
+	require.NoError(t, ot.Start(context.Background()))
+
+	corrected := ot.Result.Corrected()
+	require.Positive(t, ot.Result.Succeeded())
+	assert.Equal(t, ot.Result.Succeeded(), corrected.Succeeded())
+
+	// The target can serve only 20 RPS by the concurrency, so the requests queue up behind the schedule.
+	actual, err := ot.Result.PercentileLatency(99)
+	require.NoError(t, err)
+	correctedP99, err := corrected.PercentileLatency(99)
+	require.NoError(t, err)
+	assert.Greater(t, correctedP99, actual*2)
+}
+
+func TestStartCanceledWindow(t *testing.T) {
+	t.Parallel()
+
+	ot, err := FromConfig(&slowRequesterImpl{latency: 10 * time.Millisecond}, &setting.Setting{
+		MaxConcurrent: 1,
+		RunDuration:   10 * time.Second,
+	}, 100)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	require.NoError(t, ot.Start(ctx))
+
+	// The run ended early, so the window is far shorter than RunDuration.
+	start, end := ot.Result.Window()
+	assert.False(t, start.IsZero())
+	assert.Less(t, ot.Result.Elapsed(), 1*time.Second)
+	assert.Equal(t, ot.Result.Elapsed(), end.Sub(start))
+}
+
+func TestStartInvalidSetting(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]*setting.Setting{
+		"invalid stage": {
+			RunDuration: 1 * time.Second,
+			Stages:      []setting.Stage{{Duration: 0}},
+		},
+		"open model without RPS": {
+			RunDuration: 1 * time.Second,
+			OpenModel:   true,
 		},
-		"empty": {
-			setting:    &setting.Setting{},
-			templ:      "",
-			wantReport: "",
-			wantError:  assert.Error,
+		"open model with unlimited stage": {
+			MaxRPS:    1,
+			OpenModel: true,
+			Stages:    []setting.Stage{{Duration: 1 * time.Second, TargetRPS: 0}},
 		},
 	}
 
+	for tn, st := range testCases {
+		st := st
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			ot, err := FromConfig(&testRequesterImpl{}, st, 0)
+			require.NoError(t, err)
+			assert.Error(t, ot.Start(context.Background()))
+		})
+	}
+}
+
+func TestStartArrival(t *testing.T) {
+	t.Parallel()
+
+	base := time.Now()
+	replay, err := arrival.NewReplay([]time.Time{base, base.Add(10 * time.Millisecond), base.Add(20 * time.Millisecond), base.Add(30 * time.Millisecond)})
+	require.NoError(t, err)
+
+	ot, err := FromConfig(&testRequesterImpl{}, &setting.Setting{
+		RunDuration: 10 * time.Second,
+		Arrival:     replay,
+	}, 10)
+	require.NoError(t, err)
+
+	begin := time.Now()
+	require.NoError(t, ot.Start(context.Background()))
+	assert.Less(t, time.Since(begin), 1*time.Second, "must end when the arrival process has no more request")
+	assert.Equal(t, int64(4), ot.Result.Succeeded())
+}
+
+// recordingRequesterImpl records when each RequestOne began.
+type recordingRequesterImpl struct {
+	testRequesterImpl
+	mu      sync.Mutex
+	started []time.Time
+}
+
+func (rr *recordingRequesterImpl) RequestOne(_ context.Context) error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.started = append(rr.started, time.Now())
+	return nil
+}
+
+func TestStartReplay(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		openModel bool
+	}{
+		"closed": {openModel: false},
+		"open":   {openModel: true},
+	}
+
 	for tn, tc := range testCases {
-		tn, tc := tn, tc
+		tc := tc
 		t.Run(tn, func(t *testing.T) {
 			t.Parallel()
 
-			r, err := result.WithCapacity(3)
+			base := time.Now()
+			replay, err := arrival.NewReplay([]time.Time{base, base.Add(200 * time.Millisecond), base.Add(400 * time.Millisecond)})
 			require.NoError(t, err)
-			ot := Otchkiss{
-				Result:  r,
-				Setting: tc.setting,
-			}
-			ot.Result.AppendSuccess(1)
-			ot.Result.AppendSuccess(2)
-			ot.Result.AppendFail(3, errors.New("err1"))
+			rr := &recordingRequesterImpl{}
+			ot, err := FromConfig(rr, &setting.Setting{
+				MaxConcurrent: 1,
+				MaxRPS:        1, // Ignored by Replay, but required by the open model.
+				RunDuration:   10 * time.Second,
+				Arrival:       replay,
+				OpenModel:     tc.openModel,
+			}, 10)
+			require.NoError(t, err)
+			require.NoError(t, ot.Start(context.Background()))
 
-			report, err := ot.TemplateReport(tc.templ)
-			tc.wantError(t, err)
-			diff := cmp.Diff(tc.wantReport, report)
-			assert.Empty(t, diff)
-
-			if tn == "default" {
-				report, err := ot.Report()
-				tc.wantError(t, err)
-				diff := cmp.Diff(tc.wantReport, report)
-				assert.Empty(t, diff)
+			require.Len(t, rr.started, 3)
+			for i, want := range []time.Duration{0, 200 * time.Millisecond, 400 * time.Millisecond} {
+				assert.InDelta(t, want, rr.started[i].Sub(rr.started[0]), float64(50*time.Millisecond), "offset of request %d", i)
 			}
 		})
 	}
 }
+
+func TestFromConfigWithResult(t *testing.T) {
+	t.Parallel()
+
+	r, err := result.WithHistogram(0.01)
+	require.NoError(t, err)
+	st := &setting.Setting{RunDuration: 1 * time.Second}
+
+	ot, err := FromConfigWithResult(&testRequesterImpl{}, st, r)
+	require.NoError(t, err)
+	assert.Same(t, r, ot.Result)
+	assert.Same(t, st, ot.Setting)
+
+	_, err = FromConfigWithResult(&testRequesterImpl{}, st, nil)
+	assert.Error(t, err)
+	_, err = FromConfigWithResult(nil, st, r)
+	assert.Error(t, err)
+}
+
+type taggingRequesterImpl struct {
+	testRequesterImpl
+	count atomic.Int64
+}
+
+func (tr *taggingRequesterImpl) RequestOne(ctx context.Context) error {
+	Tag(ctx, "method", "GET")
+	if tr.count.Add(1)%2 == 0 {
+		Tag(ctx, "endpoint", "search")
+		return errors.New("not found")
+	}
+	Tag(ctx, "endpoint", "browse")
+	return nil
+}
+
+func TestStartTags(t *testing.T) {
+	t.Parallel()
+
+	ot, err := FromConfig(&taggingRequesterImpl{}, &setting.Setting{
+		MaxConcurrent: 1,
+		RunDuration:   100 * time.Millisecond,
+		Arrival:       mustReplay(t, 10),
+	}, 10)
+	require.NoError(t, err)
+	require.NoError(t, ot.Start(context.Background()))
+
+	browse := ot.Result.Tagged(result.Tag{Key: "endpoint", Value: "browse"})
+	search := ot.Result.Tagged(result.Tag{Key: "endpoint", Value: "search"})
+	get := ot.Result.Tagged(result.Tag{Key: "method", Value: "GET"})
+	assert.Len(t, ot.Result.Tags(), 3)
+	assert.Equal(t, int64(5), browse.Succeeded())
+	assert.Equal(t, int64(0), browse.Failed())
+	assert.Equal(t, int64(0), search.Succeeded())
+	assert.Equal(t, int64(5), search.Failed())
+	assert.Equal(t, int64(10), get.Succeeded()+get.Failed())
+
+	report, err := ot.Report()
+	require.NoError(t, err)
+	assert.Contains(t, report, "[Tag: endpoint=browse]\n* total: 5, failed: 0, error rate: 0 %")
+	assert.Contains(t, report, "[Tag: endpoint=search]\n* total: 5, failed: 5, error rate: 100 %")
+	assert.Contains(t, report, "[Tag: method=GET]\n* total: 10, failed: 5, error rate: 50 %")
+}
+
+type timingRequesterImpl struct {
+	testRequesterImpl
+}
+
+func (tr *timingRequesterImpl) RequestOne(ctx context.Context) error {
+	Timing(ctx, "connect", 2*time.Millisecond)
+	Timing(ctx, "ttfb", 10*time.Millisecond)
+	return nil
+}
+
+func TestStartTimings(t *testing.T) {
+	t.Parallel()
+
+	ot, err := FromConfig(&timingRequesterImpl{}, &setting.Setting{MaxConcurrent: 1, WarmUpRequests: 5, Iterations: 10}, 10)
+	require.NoError(t, err)
+	require.NoError(t, ot.Start(context.Background()))
+
+	assert.Equal(t, []string{"connect", "ttfb"}, ot.Result.Timings())
+	assert.Equal(t, int64(10), ot.Result.Timing("connect").Succeeded(), "the warm up must not be recorded")
+	v, err := ot.Result.Timing("ttfb").PercentileLatency(50)
+	require.NoError(t, err)
+	assert.InEpsilon(t, 0.01, v, 0.01)
+
+	report, err := ot.Report()
+	require.NoError(t, err)
+	assert.Contains(t, report, "[Timings (ms)]\n                count        min        med       90th       99th        max\n"+
+		"connect            10          2          2          2          2          2\n"+
+		"ttfb               10         10         10         10         10         10\n")
+
+	b, err := ot.JSONReport()
+	require.NoError(t, err)
+	var jr JSONReport
+	require.NoError(t, json.Unmarshal(b, &jr))
+	require.Len(t, jr.Timings, 2)
+	assert.Equal(t, "ttfb", jr.Timings[1].Name)
+	assert.Equal(t, int64(10), jr.Timings[1].Count)
+	assert.InEpsilon(t, 0.01, jr.Timings[1].Latency.Max, 0.01)
+}
+
+// mustReplay returns the arrival process which dispatches n requests at once.
+func mustReplay(t *testing.T, n int) *arrival.Replay {
+	t.Helper()
+
+	base := time.Now()
+	timestamps := make([]time.Time, n)
+	for i := range timestamps {
+		timestamps[i] = base
+	}
+	r, err := arrival.NewReplay(timestamps)
+	require.NoError(t, err)
+	return r
+}
@@ -0,0 +1,249 @@
+package otchkiss
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/setting"
+)
+
+// phase tracks whether the requests are sent as the warm up, the measurement or the cool down.
+type phase struct {
+	setting *setting.Setting
+
+	// warmUp is closed when the warm up ends, and coolDown is closed when the cool down begins.
+	warmUp       chan struct{}
+	coolDown     chan struct{}
+	warmUpOnce   sync.Once
+	coolDownOnce sync.Once
+
+	warmUpRequests atomic.Int64
+	stabilizer     *stabilizer
+
+	// measured and coolDowned are when the measurement and the cool down began.
+	mu         sync.Mutex
+	measured   time.Time
+	coolDowned time.Time
+}
+
+func newPhase(s *setting.Setting) *phase {
+	ph := &phase{
+		setting:  s,
+		warmUp:   make(chan struct{}),
+		coolDown: make(chan struct{}),
+	}
+	if s.WarmUpStability != nil {
+		ph.stabilizer = newStabilizer(s.WarmUpStability)
+	}
+	if s.WarmUpRequests == 0 && s.WarmUpStability == nil && s.WarmUpTime == 0 {
+		ph.endWarmUp() // End before dispatching so that no request is missed from the Result.
+	}
+	return ph
+}
+
+// dispatch counts a request about to be sent, and reports whether it is included in the Result.
+func (ph *phase) dispatch() bool {
+	if isClosed(ph.warmUp) {
+		return !isClosed(ph.coolDown)
+	}
+	if n := ph.setting.WarmUpRequests; n > 0 && ph.warmUpRequests.Add(1) >= int64(n) {
+		ph.endWarmUp()
+	}
+	return false
+}
+
+// observe feeds the latency of a warm up request to the stability detection.
+func (ph *phase) observe(elapsed time.Duration) {
+	if ph.stabilizer == nil || isClosed(ph.warmUp) {
+		return
+	}
+	if ph.stabilizer.add(elapsed.Seconds()) {
+		ph.endWarmUp()
+	}
+}
+
+func (ph *phase) endWarmUp() {
+	ph.warmUpOnce.Do(func() {
+		ph.mu.Lock()
+		ph.measured = time.Now()
+		ph.mu.Unlock()
+		close(ph.warmUp)
+	})
+}
+
+func (ph *phase) beginCoolDown() {
+	ph.coolDownOnce.Do(func() {
+		ph.mu.Lock()
+		ph.coolDowned = time.Now()
+		ph.mu.Unlock()
+		close(ph.coolDown)
+	})
+}
+
+// current returns the phase which the requests dispatched now belong to.
+func (ph *phase) current() Phase {
+	switch {
+	case !isClosed(ph.warmUp):
+		return PhaseWarmUp
+	case isClosed(ph.coolDown):
+		return PhaseCoolDown
+	default:
+		return PhaseMeasure
+	}
+}
+
+// of returns the Phase of a request dispatched now, given whether it is included in the Result.
+func (ph *phase) of(measured bool) Phase {
+	switch {
+	case measured:
+		return PhaseMeasure
+	case isClosed(ph.coolDown):
+		return PhaseCoolDown
+	default:
+		return PhaseWarmUp
+	}
+}
+
+func (ph *phase) measureBegin() time.Time {
+	ph.mu.Lock()
+	defer ph.mu.Unlock()
+	return ph.measured
+}
+
+func (ph *phase) coolDownBegin() time.Time {
+	ph.mu.Lock()
+	defer ph.mu.Unlock()
+	return ph.coolDowned
+}
+
+// finishIterations ends the measurement because the iterations are reached, and reports whether the requests continue as the cool down.
+func (ph *phase) finishIterations() bool {
+	if ph.setting.CoolDownTime == 0 {
+		return false
+	}
+	ph.beginCoolDown()
+	return true
+}
+
+// run moves the phases along the setting, and cancels ctx when the cool down ends.
+// When the iterations are reached without the cool down, the dispatch stops by itself instead, so the requests in flight are not canceled.
+func (ph *phase) run(ctx context.Context, cancel context.CancelFunc, lc *loadControl) {
+	s := ph.setting
+	if !ph.waitWarmUp(ctx) {
+		return
+	}
+	if len(s.Stages) != 0 {
+		go lc.follow(ctx, s)
+	}
+
+	var timeout <-chan time.Time
+	if d := s.MeasureDuration(); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-ctx.Done():
+		return
+	case <-ph.coolDown:
+	case <-timeout:
+		ph.beginCoolDown()
+	}
+
+	if sleepUntil(ctx, time.Now().Add(s.CoolDownTime)) {
+		cancel()
+	}
+}
+
+// waitWarmUp blocks until the warm up ends, and returns false if ctx is done before that.
+func (ph *phase) waitWarmUp(ctx context.Context) bool {
+	var limit time.Duration
+	switch s := ph.setting; {
+	case s.WarmUpRequests > 0:
+	case s.WarmUpStability != nil:
+		limit = s.WarmUpStability.MaxTime
+	default:
+		limit = s.WarmUpTime
+	}
+
+	var timeout <-chan time.Time
+	if limit > 0 {
+		timer := time.NewTimer(limit)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-ph.warmUp:
+	case <-timeout:
+		ph.endWarmUp()
+	}
+	return true
+}
+
+// stabilizer detects when the latencies settle by comparing the percentiles of the latest window with the ones of the previous window.
+type stabilizer struct {
+	window      int
+	tolerance   float64
+	percentiles []int
+
+	// recent is the ring buffer of the latest two windows, and n is the number of the latencies added so far.
+	mu     sync.Mutex
+	recent []float64
+	n      int
+}
+
+func newStabilizer(st *setting.Stability) *stabilizer {
+	w := st.WindowSize()
+	return &stabilizer{
+		window:      w,
+		tolerance:   st.Tolerance,
+		percentiles: st.ComparedPercentiles(),
+		recent:      make([]float64, 2*w),
+	}
+}
+
+// add records a latency in seconds, and reports whether the latencies have settled.
+// The windows slide by a tenth of their size between the comparisons, so that sorting them does not cost on every request.
+func (st *stabilizer) add(v float64) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.recent[st.n%len(st.recent)] = v
+	st.n++
+	if st.n < len(st.recent) || st.n%max(st.window/10, 1) != 0 {
+		return false
+	}
+
+	// The oldest latency is at n, because the ring buffer is full.
+	previous := make([]float64, 0, st.window)
+	latest := make([]float64, 0, st.window)
+	for i := range st.recent {
+		v := st.recent[(st.n+i)%len(st.recent)]
+		if i < st.window {
+			previous = append(previous, v)
+		} else {
+			latest = append(latest, v)
+		}
+	}
+	sort.Float64s(previous)
+	sort.Float64s(latest)
+
+	for _, p := range st.percentiles {
+		before, after := percentileOf(previous, p), percentileOf(latest, p)
+		if math.Abs(after-before) > st.tolerance*before {
+			return false
+		}
+	}
+	return true
+}
+
+// percentileOf returns the p-th percentile of the sorted values.
+func percentileOf(sorted []float64, p int) float64 {
+	return sorted[min(len(sorted)*p/100, len(sorted)-1)]
+}
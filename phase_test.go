@@ -0,0 +1,159 @@
+package otchkiss
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type phaseRequesterImpl struct {
+	testRequesterImpl
+	latency  time.Duration
+	requests atomic.Int64
+}
+
+func (pr *phaseRequesterImpl) RequestOne(ctx context.Context) error {
+	pr.requests.Add(1)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(pr.latency):
+		return nil
+	}
+}
+
+func TestStabilizer(t *testing.T) {
+	t.Parallel()
+
+	st := newStabilizer(&setting.Stability{Window: 10, Tolerance: 0.1})
+
+	// The latencies keep growing, so the windows never settle.
+	for i := 0; i < 100; i++ {
+		assert.False(t, st.add(float64(i+1)))
+	}
+
+	// The latest window is still compared with the growing one until it slides out.
+	var settled bool
+	for i := 0; i < 20 && !settled; i++ {
+		settled = st.add(1)
+	}
+	assert.True(t, settled)
+}
+
+func TestStartWarmUpRequests(t *testing.T) {
+	t.Parallel()
+
+	pr := &phaseRequesterImpl{}
+	ot, err := FromConfig(pr, &setting.Setting{MaxConcurrent: 1, Iterations: 20, WarmUpRequests: 10}, 20)
+	require.NoError(t, err)
+	require.NoError(t, ot.Start(context.Background()))
+
+	assert.Equal(t, int64(20), ot.Result.Succeeded())
+	assert.Equal(t, int64(30), pr.requests.Load())
+
+	report, err := ot.Report()
+	require.NoError(t, err)
+	assert.Contains(t, report, "* warm up:        10 requests\n")
+}
+
+func TestStartWarmUpStability(t *testing.T) {
+	t.Parallel()
+
+	pr := &phaseRequesterImpl{latency: 1 * time.Millisecond}
+	ot, err := FromConfig(pr, &setting.Setting{
+		MaxConcurrent:   1,
+		Iterations:      5,
+		WarmUpStability: &setting.Stability{Window: 10, Tolerance: 10, MaxTime: 5 * time.Second},
+	}, 5)
+	require.NoError(t, err)
+	require.NoError(t, ot.Start(context.Background()))
+
+	assert.Equal(t, int64(5), ot.Result.Succeeded())
+	assert.GreaterOrEqual(t, pr.requests.Load(), int64(25), "the warm up needs two windows")
+
+	report, err := ot.Report()
+	require.NoError(t, err)
+	assert.Contains(t, report, "* warm up:        until p50, p99 within 1,000 % over 10 requests\n")
+}
+
+func TestStartCoolDown(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		latency    time.Duration
+		coolDown   time.Duration
+		wantFailed assert.ComparisonAssertionFunc
+	}{
+		"cool down": {
+			latency:    50 * time.Millisecond,
+			coolDown:   200 * time.Millisecond,
+			wantFailed: assert.Equal,
+		},
+		"no cool down cancels the requests in flight": {
+			latency:    1 * time.Second, // Long enough to be still in flight however slow the cancel is.
+			coolDown:   0,
+			wantFailed: assert.Less,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			pr := &phaseRequesterImpl{latency: tc.latency}
+			ot, err := FromConfig(pr, &setting.Setting{
+				MaxConcurrent: 2,
+				RunDuration:   200 * time.Millisecond,
+				CoolDownTime:  tc.coolDown,
+			}, 20)
+			require.NoError(t, err)
+
+			begin := time.Now()
+			require.NoError(t, ot.Start(context.Background()))
+			assert.GreaterOrEqual(t, time.Since(begin), 200*time.Millisecond+tc.coolDown)
+			tc.wantFailed(t, int64(0), ot.Result.Failed())
+			assert.Less(t, ot.measuredDuration(), 300*time.Millisecond, "the cool down is not measured")
+		})
+	}
+}
+
+func TestStartPhaseInvalid(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]*setting.Setting{
+		"negative warm up requests": {
+			RunDuration:    1 * time.Second,
+			WarmUpRequests: -1,
+		},
+		"no tolerance": {
+			RunDuration:     1 * time.Second,
+			WarmUpStability: &setting.Stability{Window: 10},
+		},
+		"requests and stability": {
+			RunDuration:     1 * time.Second,
+			WarmUpRequests:  10,
+			WarmUpStability: &setting.Stability{Tolerance: 0.1},
+		},
+		"negative cool down": {
+			RunDuration:  1 * time.Second,
+			CoolDownTime: -1 * time.Second,
+		},
+	}
+
+	for tn, st := range testCases {
+		st := st
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			ot, err := FromConfig(&testRequesterImpl{}, st, 0)
+			require.NoError(t, err)
+			assert.Error(t, ot.Start(context.Background()))
+		})
+	}
+}
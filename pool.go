@@ -0,0 +1,114 @@
+package otchkiss
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/setting"
+)
+
+// lateThreshold defines how long a job can wait in the backlog after its scheduled time before it is counted as late.
+const lateThreshold = 10 * time.Millisecond
+
+// job is a scheduled call of RequestOne.
+type job struct {
+	scenario Scenario
+	stage    int
+
+	// scheduled is when the job is dispatched, it is the intended start time in the open model.
+	scheduled time.Time
+
+	// measured reports whether the job was dispatched after the warm up, so that it is included in the Result.
+	measured bool
+
+	// phase is the Phase which the job was dispatched in.
+	phase Phase
+}
+
+// pool runs the jobs in the backlog by the bounded number of workers.
+// Fixed workers are all started up front, otherwise a worker is added only when idle ones cannot drain the backlog.
+type pool struct {
+	jobs       chan job
+	run        func(job)
+	maxWorkers int
+	idle       atomic.Int64
+	wg         sync.WaitGroup
+
+	mu      sync.Mutex
+	workers int
+}
+
+func newPool(s *setting.Setting, run func(job)) *pool {
+	p := &pool{
+		jobs:       make(chan job, s.BacklogSize()),
+		run:        run,
+		maxWorkers: s.WorkerLimit(),
+	}
+	for i := 0; i < s.Workers; i++ {
+		p.spawn()
+	}
+	return p
+}
+
+func (p *pool) spawn() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.workers >= p.maxWorkers {
+		return
+	}
+	p.workers++
+	p.wg.Add(1)
+	go p.work()
+}
+
+func (p *pool) work() {
+	defer p.wg.Done()
+	for {
+		p.idle.Add(1)
+		j, ok := <-p.jobs
+		p.idle.Add(-1)
+		if !ok {
+			return
+		}
+		// The job may have been taken by the worker counted as idle when it was queued, so check again.
+		p.grow()
+		p.run(j)
+	}
+}
+
+// grow adds a worker when the backlog has more jobs than the idle workers.
+func (p *pool) grow() {
+	if int64(len(p.jobs)) > p.idle.Load() {
+		p.spawn()
+	}
+}
+
+// submit queues j, waiting for room in the backlog, and returns false if ctx is done before that.
+func (p *pool) submit(ctx context.Context, j job) bool {
+	select {
+	case p.jobs <- j:
+	case <-ctx.Done():
+		return false
+	}
+	p.grow()
+	return true
+}
+
+// trySubmit queues j without waiting, and returns false when the backlog is full.
+func (p *pool) trySubmit(j job) bool {
+	select {
+	case p.jobs <- j:
+	default:
+		return false
+	}
+	p.grow()
+	return true
+}
+
+// close stops accepting jobs, and waits until the workers finish the queued ones.
+func (p *pool) close() {
+	close(p.jobs)
+	p.wg.Wait()
+}
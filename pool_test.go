@@ -0,0 +1,133 @@
+package otchkiss
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		setting     *setting.Setting
+		wantWorkers int64
+	}{
+		"fixed": {
+			setting:     &setting.Setting{Workers: 3},
+			wantWorkers: 3,
+		},
+		"elastic": {
+			setting:     &setting.Setting{MaxConcurrent: 5},
+			wantWorkers: 5,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			var running, peak, done atomic.Int64
+			p := newPool(tc.setting, func(job) {
+				n := running.Add(1)
+				for {
+					m := peak.Load()
+					if n <= m || peak.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				running.Add(-1)
+				done.Add(1)
+			})
+			for i := 0; i < 100; i++ {
+				require.True(t, p.submit(context.Background(), job{}))
+			}
+			p.close()
+
+			assert.Equal(t, int64(100), done.Load())
+			assert.LessOrEqual(t, peak.Load(), tc.wantWorkers)
+			assert.LessOrEqual(t, int64(p.workers), tc.wantWorkers)
+		})
+	}
+}
+
+func TestPoolBacklog(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	p := newPool(&setting.Setting{Workers: 1, MaxBacklog: 2}, func(job) {
+		<-release
+	})
+
+	// The worker holds one job, and the backlog holds two more.
+	require.True(t, p.trySubmit(job{}))
+	require.Eventually(t, func() bool { return len(p.jobs) == 0 }, time.Second, time.Millisecond)
+	assert.True(t, p.trySubmit(job{}))
+	assert.True(t, p.trySubmit(job{}))
+	assert.False(t, p.trySubmit(job{}), "must not queue more than the backlog")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.False(t, p.submit(ctx, job{}), "must give up waiting for room when ctx is done")
+
+	close(release)
+	p.close()
+}
+
+func TestStartOpenModelDropped(t *testing.T) {
+	t.Parallel()
+
+	ot, err := FromConfig(&slowRequesterImpl{latency: 50 * time.Millisecond}, &setting.Setting{
+		MaxConcurrent: 1,
+		MaxRPS:        200,
+		RunDuration:   300 * time.Millisecond,
+		OpenModel:     true,
+		MaxBacklog:    2,
+	}, 100)
+	require.NoError(t, err)
+	require.NoError(t, ot.Start(context.Background()))
+
+	// A worker serves only 20 RPS, so most of the scheduled requests cannot wait in the backlog.
+	assert.Positive(t, ot.Result.Dropped())
+	assert.Positive(t, ot.Result.Late())
+	assert.Less(t, ot.Result.Succeeded(), int64(20))
+
+	report, err := ot.Report()
+	require.NoError(t, err)
+	assert.Contains(t, report, "* dropped:    ")
+	assert.Contains(t, report, "* late:       ")
+}
+
+// BenchmarkPool and BenchmarkGoroutinePerJob compare the overhead of running jobs by the pool and by a goroutine per job.
+func BenchmarkPool(b *testing.B) {
+	p := newPool(&setting.Setting{MaxConcurrent: 64}, func(job) {})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.submit(context.Background(), job{})
+	}
+	p.close()
+}
+
+func BenchmarkGoroutinePerJob(b *testing.B) {
+	run := func(job) {}
+	var wg sync.WaitGroup
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(job{})
+		}()
+	}
+	wg.Wait()
+}
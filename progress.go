@@ -0,0 +1,249 @@
+package otchkiss
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/ryo-yamaoka/otchkiss/setting"
+
+	humanize "github.com/dustin/go-humanize"
+)
+
+// defaultProgressInterval is how often the progress is reported when ProgressInterval is 0.
+const defaultProgressInterval = 1 * time.Second
+
+// Phase is the part of the test which the requests are sent in.
+type Phase int
+
+const (
+	PhaseWarmUp Phase = iota
+	PhaseMeasure
+	PhaseCoolDown
+
+	// PhaseDone is reported once after all the requests completed.
+	PhaseDone
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseWarmUp:
+		return "warm up"
+	case PhaseMeasure:
+		return "measuring"
+	case PhaseCoolDown:
+		return "cool down"
+	case PhaseDone:
+		return "done"
+	default:
+		return fmt.Sprintf("Phase(%d)", int(p))
+	}
+}
+
+// Progress is the state of the running test.
+// The rolling statistics are of the requests which completed since the previous report, including the ones of the warm up and the cool down.
+type Progress struct {
+	Phase Phase
+
+	// Elapsed is the time since the requests began.
+	Elapsed time.Duration
+
+	// Remaining is the expected time until the end of the cool down, it is 0 when it is unknown, such as the warm up until the latencies settle.
+	Remaining time.Duration
+
+	// InFlight is the number of RequestOne running now.
+	InFlight int64
+
+	// Measured is the number of the requests included in the Result so far.
+	Measured int64
+
+	// RPS is the number of the requests completed per second.
+	RPS float64
+
+	// ErrorRate is the percentage of the failed requests.
+	ErrorRate float64
+
+	// Latency50p and Latency99p are the latency percentiles in seconds, they are 0 when no request completed.
+	Latency50p float64
+	Latency99p float64
+}
+
+// ProgressSink receives the progress of the test.
+// Report is called from one goroutine at a time, so it does not need to be thread safe.
+type ProgressSink interface {
+	Report(p Progress)
+}
+
+// ProgressFunc is the function which receives the progress of the test.
+type ProgressFunc func(p Progress)
+
+func (f ProgressFunc) Report(p Progress) {
+	f(p)
+}
+
+// TerminalProgress renders the progress in a single line, which is rewritten on every report.
+type TerminalProgress struct {
+	w io.Writer
+}
+
+// NewTerminalProgress returns ProgressSink which renders the progress to w, usually os.Stderr.
+func NewTerminalProgress(w io.Writer) *TerminalProgress {
+	return &TerminalProgress{w: w}
+}
+
+func (tp *TerminalProgress) Report(p Progress) {
+	remaining := "-"
+	if p.Remaining > 0 {
+		remaining = p.Remaining.Round(time.Second).String()
+	}
+	// \r goes back to the beginning of the line, and \x1b[K clears the rest of the previous line.
+	fmt.Fprintf(tp.w, "\r[%s] elapsed: %s, remaining: %s, RPS: %s, in flight: %s, error rate: %s %%, p50: %s ms, p99: %s ms\x1b[K",
+		p.Phase,
+		p.Elapsed.Round(time.Second),
+		remaining,
+		humanize.CommafWithDigits(p.RPS, 1),
+		humanize.Comma(p.InFlight),
+		humanize.CommafWithDigits(p.ErrorRate, 1),
+		humanize.CommafWithDigits(p.Latency50p*1000, 1),
+		humanize.CommafWithDigits(p.Latency99p*1000, 1),
+	)
+	if p.Phase == PhaseDone {
+		fmt.Fprintln(tp.w)
+	}
+}
+
+// progressMonitor counts the requests and reports the progress periodically.
+type progressMonitor struct {
+	sink     ProgressSink
+	interval time.Duration
+	setting  *setting.Setting
+	result   *result.Result
+	phase    *phase
+	begin    time.Time
+
+	inFlight atomic.Int64
+
+	// latencies and failed are of the requests completed since the previous report.
+	mu        sync.Mutex
+	latencies []float64
+	failed    int64
+	last      time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newProgressMonitor(sink ProgressSink, interval time.Duration, s *setting.Setting, r *result.Result, ph *phase) *progressMonitor {
+	if interval == 0 {
+		interval = defaultProgressInterval
+	}
+	now := time.Now()
+	return &progressMonitor{
+		sink:     sink,
+		interval: interval,
+		setting:  s,
+		result:   r,
+		phase:    ph,
+		begin:    now,
+		last:     now,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// requestBegun counts a RequestOne about to run.
+func (m *progressMonitor) requestBegun() {
+	m.inFlight.Add(1)
+}
+
+// requestDone counts a RequestOne which completed.
+func (m *progressMonitor) requestDone(elapsed time.Duration, err error) {
+	m.inFlight.Add(-1)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latencies = append(m.latencies, elapsed.Seconds())
+	if err != nil {
+		m.failed++
+	}
+}
+
+// run reports the progress every interval until close is called.
+func (m *progressMonitor) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			m.sink.Report(m.progress(now, m.phase.current()))
+		}
+	}
+}
+
+// close stops the periodic reports, and reports PhaseDone.
+func (m *progressMonitor) close() {
+	close(m.stop)
+	<-m.done
+	m.sink.Report(m.progress(time.Now(), PhaseDone))
+}
+
+// progress returns the progress at now, and begins the next rolling statistics.
+func (m *progressMonitor) progress(now time.Time, ph Phase) Progress {
+	m.mu.Lock()
+	latencies, failed, last := m.latencies, m.failed, m.last
+	m.latencies, m.failed, m.last = nil, 0, now
+	m.mu.Unlock()
+
+	p := Progress{
+		Phase:     ph,
+		Elapsed:   now.Sub(m.begin),
+		Remaining: m.remaining(now, ph),
+		InFlight:  m.inFlight.Load(),
+		Measured:  m.result.Succeeded() + m.result.Failed(),
+	}
+	if n := len(latencies); n != 0 {
+		if d := now.Sub(last); d > 0 {
+			p.RPS = float64(n) / d.Seconds()
+		}
+		p.ErrorRate = float64(failed) / float64(n) * 100
+		sort.Float64s(latencies)
+		p.Latency50p = percentileOf(latencies, 50)
+		p.Latency99p = percentileOf(latencies, 99)
+	}
+	return p
+}
+
+// remaining returns the expected time until the end of the cool down, or 0 if it is unknown.
+func (m *progressMonitor) remaining(now time.Time, ph Phase) time.Duration {
+	s := m.setting
+	measure := s.MeasureDuration()
+	if s.Iterative() {
+		measure = 0 // MaxDuration is only the limit.
+	}
+	var end time.Time
+	switch ph {
+	case PhaseWarmUp:
+		if s.WarmUpRequests != 0 || s.WarmUpStability != nil || measure == 0 {
+			return 0
+		}
+		end = m.begin.Add(s.WarmUpTime + measure + s.CoolDownTime)
+	case PhaseMeasure:
+		if measure == 0 {
+			return 0
+		}
+		end = m.phase.measureBegin().Add(measure + s.CoolDownTime)
+	case PhaseCoolDown:
+		end = m.phase.coolDownBegin().Add(s.CoolDownTime)
+	default:
+		return 0
+	}
+	return max(end.Sub(now), 0)
+}
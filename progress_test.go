@@ -0,0 +1,133 @@
+package otchkiss
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/ryo-yamaoka/otchkiss/setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartProgress(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		factory bool
+	}{
+		"pool":          {factory: false},
+		"virtual users": {factory: true},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			var reports []Progress
+			st := &setting.Setting{
+				MaxConcurrent: 2,
+				WarmUpTime:    200 * time.Millisecond,
+				RunDuration:   400 * time.Millisecond,
+				CoolDownTime:  200 * time.Millisecond,
+			}
+			pr := &phaseRequesterImpl{latency: 5 * time.Millisecond}
+			var ot *Otchkiss
+			var err error
+			if tc.factory {
+				ot, err = FromVirtualUsers(func(int) (Requester, error) { return pr, nil }, st, 0)
+			} else {
+				ot, err = FromConfig(pr, st, 0)
+			}
+			require.NoError(t, err)
+			ot.ProgressInterval = 50 * time.Millisecond
+			ot.Progress = ProgressFunc(func(p Progress) {
+				mu.Lock()
+				defer mu.Unlock()
+				reports = append(reports, p)
+			})
+			require.NoError(t, ot.Start(context.Background()))
+
+			mu.Lock()
+			defer mu.Unlock()
+			require.Greater(t, len(reports), 5)
+			phases := map[Phase]bool{}
+			for _, p := range reports {
+				phases[p.Phase] = true
+				assert.LessOrEqual(t, p.InFlight, int64(2))
+				assert.LessOrEqual(t, p.Remaining, 800*time.Millisecond)
+			}
+			assert.True(t, phases[PhaseWarmUp])
+			assert.True(t, phases[PhaseMeasure])
+			assert.True(t, phases[PhaseCoolDown])
+
+			last := reports[len(reports)-1]
+			assert.Equal(t, PhaseDone, last.Phase)
+			assert.Equal(t, ot.Result.Succeeded(), last.Measured)
+			assert.Equal(t, int64(0), last.InFlight)
+
+			for _, p := range reports[:len(reports)-1] {
+				if p.Phase == PhaseMeasure && p.RPS > 0 {
+					assert.Greater(t, p.Latency50p, 0.004)
+					assert.Greater(t, p.Remaining, time.Duration(0))
+					return
+				}
+			}
+			t.Error("no progress of the measurement with RPS")
+		})
+	}
+}
+
+func TestProgressMonitor(t *testing.T) {
+	t.Parallel()
+
+	r, err := result.New()
+	require.NoError(t, err)
+	st := &setting.Setting{RunDuration: 10 * time.Second, CoolDownTime: 5 * time.Second}
+	ph := newPhase(st) // No warm up, so the measurement begins now.
+	m := newProgressMonitor(ProgressFunc(func(Progress) {}), 0, st, r, ph)
+	assert.Equal(t, defaultProgressInterval, m.interval)
+
+	for i := 0; i < 4; i++ {
+		m.requestBegun()
+	}
+	m.requestDone(10*time.Millisecond, nil)
+	m.requestDone(20*time.Millisecond, nil)
+	m.requestDone(30*time.Millisecond, errors.New("err"))
+
+	now := m.last.Add(500 * time.Millisecond)
+	p := m.progress(now, ph.current())
+	assert.Equal(t, PhaseMeasure, p.Phase)
+	assert.Equal(t, int64(1), p.InFlight)
+	assert.Equal(t, 6.0, p.RPS)
+	assert.InDelta(t, 33.3, p.ErrorRate, 0.1)
+	assert.Equal(t, 0.02, p.Latency50p)
+	assert.Equal(t, 0.03, p.Latency99p)
+	assert.InDelta(t, (15*time.Second - 500*time.Millisecond).Seconds(), p.Remaining.Seconds(), 0.1)
+
+	// The rolling statistics begin again.
+	p = m.progress(now.Add(time.Second), ph.current())
+	assert.Zero(t, p.RPS)
+	assert.Zero(t, p.Latency99p)
+}
+
+func TestTerminalProgress(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	tp := NewTerminalProgress(&buf)
+	tp.Report(Progress{Phase: PhaseMeasure, Elapsed: 3 * time.Second, Remaining: 2 * time.Second, RPS: 1234.5, InFlight: 3, ErrorRate: 0.5, Latency50p: 0.0123, Latency99p: 0.0456})
+	tp.Report(Progress{Phase: PhaseDone, Elapsed: 5 * time.Second})
+
+	lines := strings.Split(buf.String(), "\r")
+	require.Len(t, lines, 3)
+	assert.Equal(t, "[measuring] elapsed: 3s, remaining: 2s, RPS: 1,234.5, in flight: 3, error rate: 0.5 %, p50: 12.3 ms, p99: 45.6 ms\x1b[K", lines[1])
+	assert.Equal(t, "[done] elapsed: 5s, remaining: -, RPS: 0, in flight: 0, error rate: 0 %, p50: 0 ms, p99: 0 ms\x1b[K\n", lines[2])
+}
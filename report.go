@@ -0,0 +1,539 @@
+package otchkiss
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/ryo-yamaoka/otchkiss/setting"
+
+	humanize "github.com/dustin/go-humanize"
+)
+
+type ReportParams struct {
+	TotalRequests string
+	Succeeded     string
+	Failed        string
+	WarmUpTime    string
+	Duration      string
+	MaxConcurrent int
+	MaxRPS        int
+	ErrorRate     string
+	RPS           string
+	MaxLatency    string
+	MinLatency    string
+	AvgLatency    string
+	MedLatency    string
+	Latency99p    string
+	Latency90p    string
+	Histogram     string
+	Stages        []StageReportParams
+	Tags          []TagReportParams
+	Scenarios     []ScenarioReportParams
+
+	// WarmUpRequests is the number of the warm up requests, it is 0 when the warm up is not defined by the count.
+	// WarmUpStability describes when the latencies are regarded as settled, it is empty when the warm up is not defined by the stability.
+	// CoolDownTime is empty when there is no cool down.
+	WarmUpRequests  int
+	WarmUpStability string
+	CoolDownTime    string
+
+	// Iterations is the number of the requests to measure, it is 0 when the measurement is defined by the duration.
+	Iterations int
+
+	// TargetRPS is the average max RPS of the setting, and AchievedRPS is the percentage of RPS to it.
+	// They are empty when the RPS is unlimited.
+	TargetRPS   string
+	AchievedRPS string
+
+	// Dropped and Late are the requests which the generator could not send on schedule, they are empty when there is none.
+	Dropped string
+	Late    string
+
+	// CorrectedLatency is the latency measured from the intended start times, it is nil unless the test runs in the open model.
+	CorrectedLatency *LatencyReportParams
+
+	// Thresholds are the outcomes of Otchkiss.Thresholds, and Verdict is PASS or FAIL of them.
+	// They are empty when no threshold is specified.
+	Thresholds []ThresholdReportParams
+	Verdict    string
+
+	// Errors are the most frequent error groups, and OtherErrorKinds is the number of the groups not included in them.
+	Errors          []ErrorReportParams
+	OtherErrorKinds int
+
+	// RPSChart and Latency99pChart are the charts of the time series, they are empty when nothing is recorded.
+	RPSChart        string
+	Latency99pChart string
+
+	// Timings are the durations of the parts of the requests recorded by Timing, they are empty when nothing is recorded.
+	Timings []TimingReportParams
+}
+
+// SummaryReportParams is the statistics of a part of the requests, such as a tag or a scenario.
+type SummaryReportParams struct {
+	TotalRequests string
+	Succeeded     string
+	Failed        string
+	ErrorRate     string
+	RPS           string
+	LatencyReportParams
+}
+
+type TagReportParams struct {
+	Tag string
+	SummaryReportParams
+}
+
+type ScenarioReportParams struct {
+	Name   string
+	Weight int
+	// Share is the percentage of the requests of the scenario in the total requests.
+	Share string
+	SummaryReportParams
+}
+
+// TimingReportParams is the statistics of the durations of a part of the requests, such as the DNS lookup of HTTP.
+type TimingReportParams struct {
+	Name  string
+	Count string
+	LatencyReportParams
+}
+
+type ErrorReportParams struct {
+	Key       string
+	Count     string
+	Rate      string
+	FirstSeen string
+	LastSeen  string
+}
+
+type ThresholdReportParams struct {
+	Threshold string
+	// Result is PASS or FAIL.
+	Result string
+	// Observed is the value of the metric, or why it could not be observed.
+	Observed string
+}
+
+type LatencyReportParams struct {
+	MaxLatency string
+	MinLatency string
+	AvgLatency string
+	MedLatency string
+	Latency99p string
+	Latency90p string
+}
+
+type StageReportParams struct {
+	Index            int
+	Duration         string
+	TargetRPS        int
+	TargetConcurrent int
+	TotalRequests    string
+	Succeeded        string
+	Failed           string
+	ErrorRate        string
+	RPS              string
+	MedLatency       string
+	Latency99p       string
+}
+
+// Report outputs result of Otchkiss testing by default template.
+func (ot *Otchkiss) Report() (string, error) {
+	return ot.report(defaultReportTemplate)
+}
+
+// TemplateReport outputs result of Otchkiss testing by user template.
+// See `template.go` for a sample.
+func (ot *Otchkiss) TemplateReport(template string) (string, error) {
+	return ot.report(template)
+}
+
+func (ot *Otchkiss) report(templ string) (string, error) {
+	if templ == "" {
+		return "", errors.New("empty template")
+	}
+
+	rp, err := ot.reportParam()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate report parameters: %w", err)
+	}
+
+	tmpl, err := template.New("").Parse(templ)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse report format: %w", err)
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := tmpl.Execute(buf, rp); err != nil {
+		return "", fmt.Errorf("failed to rendering report: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+func (ot *Otchkiss) reportParam() (*ReportParams, error) {
+	succeeded := ot.Result.Succeeded()
+	failed := ot.Result.Failed()
+	total := succeeded + failed
+
+	lp, err := latencyReportParams(ot.Result)
+	if err != nil {
+		return nil, err
+	}
+	hist, err := ot.Result.Histogram(9, 25)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate histogram: %w", err)
+	}
+	stages, err := ot.stageReportParams()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate stage report: %w", err)
+	}
+
+	tags, err := ot.tagReportParams()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tag report: %w", err)
+	}
+	scenarios, err := ot.scenarioReportParams()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate scenario report: %w", err)
+	}
+	timings, err := ot.timingReportParams()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate timing report: %w", err)
+	}
+	rpsChart, p99Chart := timeSeriesCharts(ot.Result.TimeSeries())
+	errs, otherErrs := errorReportParams(ot.Result.ErrorGroups(), total)
+
+	var dropped, late string
+	if n := ot.Result.Dropped(); n != 0 {
+		dropped = humanize.Comma(n)
+	}
+	if n := ot.Result.Late(); n != 0 {
+		late = humanize.Comma(n)
+	}
+
+	rps := float64(total) / ot.measuredDuration().Seconds()
+	var targetRPS, achievedRPS string
+	if target := ot.Setting.TargetRPS(); target != 0 {
+		targetRPS = humanize.CommafWithDigits(target, 1)
+		achievedRPS = humanize.CommafWithDigits(rps/target*100, 1)
+	}
+
+	thresholds, verdict := ot.thresholdReportParams()
+
+	var corrected *LatencyReportParams
+	if ot.Result.HasCorrected() {
+		corrected, err = latencyReportParams(ot.Result.Corrected())
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate corrected latency report: %w", err)
+		}
+	}
+
+	return &ReportParams{
+		TotalRequests:    humanize.Comma(total),
+		Succeeded:        humanize.Comma(succeeded),
+		Failed:           humanize.Comma(failed),
+		WarmUpTime:       ot.Setting.WarmUpTime.String(),
+		Duration:         ot.measuredDuration().String(),
+		WarmUpRequests:   ot.Setting.WarmUpRequests,
+		WarmUpStability:  stabilityReport(ot.Setting.WarmUpStability),
+		CoolDownTime:     coolDownReport(ot.Setting.CoolDownTime),
+		Iterations:       ot.Setting.Iterations,
+		MaxConcurrent:    ot.Setting.MaxConcurrent,
+		MaxRPS:           ot.Setting.MaxRPS,
+		ErrorRate:        humanize.CommafWithDigits(float64(failed)/float64(total)*100, 1),
+		RPS:              humanize.CommafWithDigits(rps, 1),
+		TargetRPS:        targetRPS,
+		AchievedRPS:      achievedRPS,
+		MaxLatency:       lp.MaxLatency,
+		MinLatency:       lp.MinLatency,
+		AvgLatency:       lp.AvgLatency,
+		MedLatency:       lp.MedLatency,
+		Latency99p:       lp.Latency99p,
+		Latency90p:       lp.Latency90p,
+		Histogram:        hist,
+		Stages:           stages,
+		Tags:             tags,
+		Scenarios:        scenarios,
+		Dropped:          dropped,
+		Late:             late,
+		CorrectedLatency: corrected,
+		Thresholds:       thresholds,
+		Verdict:          verdict,
+		Errors:           errs,
+		OtherErrorKinds:  otherErrs,
+		RPSChart:         rpsChart,
+		Latency99pChart:  p99Chart,
+		Timings:          timings,
+	}, nil
+}
+
+func (ot *Otchkiss) thresholdReportParams() ([]ThresholdReportParams, string) {
+	if len(ot.Thresholds) == 0 {
+		return nil, ""
+	}
+
+	v := ot.Verdict()
+	params := make([]ThresholdReportParams, 0, len(v.Outcomes))
+	for _, o := range v.Outcomes {
+		observed := o.Threshold.Metric.Format(o.Observed)
+		if o.Err != nil {
+			observed = o.Err.Error()
+		}
+		params = append(params, ThresholdReportParams{
+			Threshold: o.Threshold.String(),
+			Result:    passOrFail(o.Passed),
+			Observed:  observed,
+		})
+	}
+	return params, passOrFail(v.Passed)
+}
+
+func passOrFail(passed bool) string {
+	if passed {
+		return "PASS"
+	}
+	return "FAIL"
+}
+
+func stabilityReport(st *setting.Stability) string {
+	if st == nil {
+		return ""
+	}
+	ps := make([]string, 0, len(st.ComparedPercentiles()))
+	for _, p := range st.ComparedPercentiles() {
+		ps = append(ps, fmt.Sprintf("p%d", p))
+	}
+	return fmt.Sprintf("%s within %s %% over %d requests", strings.Join(ps, ", "), humanize.CommafWithDigits(st.Tolerance*100, 1), st.WindowSize())
+}
+
+func coolDownReport(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// measuredDuration returns the measurement window of the Result, or the configured duration if nothing is in the window.
+func (ot *Otchkiss) measuredDuration() time.Duration {
+	if d := ot.Result.Elapsed(); d > 0 {
+		return d
+	}
+	return ot.Setting.MeasureDuration()
+}
+
+// reportErrorGroups defines how many error groups are shown in the report.
+const reportErrorGroups = 5
+
+func errorReportParams(groups []result.ErrorGroup, total int64) ([]ErrorReportParams, int) {
+	var others int
+	if len(groups) > reportErrorGroups {
+		others = len(groups) - reportErrorGroups
+		groups = groups[:reportErrorGroups]
+	}
+
+	params := make([]ErrorReportParams, 0, len(groups))
+	for _, g := range groups {
+		params = append(params, ErrorReportParams{
+			Key:       g.Key,
+			Count:     humanize.Comma(g.Count),
+			Rate:      humanize.CommafWithDigits(float64(g.Count)/float64(total)*100, 1),
+			FirstSeen: g.FirstSeen.Format(time.RFC3339Nano),
+			LastSeen:  g.LastSeen.Format(time.RFC3339Nano),
+		})
+	}
+	return params, others
+}
+
+const (
+	chartHeight = 5
+	chartWidth  = 60
+)
+
+func timeSeriesCharts(points []result.Point) (rpsChart, p99Chart string) {
+	if len(points) == 0 {
+		return "", ""
+	}
+
+	rps := make([]float64, 0, len(points))
+	p99 := make([]float64, 0, len(points))
+	for _, p := range points {
+		rps = append(rps, p.RPS())
+		p99 = append(p99, p.Latency99p*1000)
+	}
+	span := time.Duration(len(points)) * points[0].Interval
+	format := func(v float64) string {
+		return humanize.CommafWithDigits(v, 1)
+	}
+
+	// The latency takes the worst of the merged intervals so that spikes are not hidden.
+	return chart(rps, chartHeight, chartWidth, span, averageOf, format), chart(p99, chartHeight, chartWidth, span, maxOf, format)
+}
+
+func (ot *Otchkiss) tagReportParams() ([]TagReportParams, error) {
+	tags := ot.Result.Tags()
+	params := make([]TagReportParams, 0, len(tags))
+	for _, t := range tags {
+		sp, err := ot.summaryReportParams(ot.Result.Tagged(t))
+		if err != nil {
+			return nil, fmt.Errorf("tag %s: %w", t, err)
+		}
+		params = append(params, TagReportParams{
+			Tag:                 t.String(),
+			SummaryReportParams: *sp,
+		})
+	}
+	return params, nil
+}
+
+func (ot *Otchkiss) timingReportParams() ([]TimingReportParams, error) {
+	names := ot.Result.Timings()
+	params := make([]TimingReportParams, 0, len(names))
+	for _, name := range names {
+		r := ot.Result.Timing(name)
+		lp, err := latencyReportParams(r)
+		if err != nil {
+			return nil, fmt.Errorf("timing %s: %w", name, err)
+		}
+		params = append(params, TimingReportParams{
+			Name:                name,
+			Count:               humanize.Comma(r.Succeeded()),
+			LatencyReportParams: *lp,
+		})
+	}
+	return params, nil
+}
+
+func (ot *Otchkiss) scenarioReportParams() ([]ScenarioReportParams, error) {
+	total := ot.Result.Succeeded() + ot.Result.Failed()
+	params := make([]ScenarioReportParams, 0, len(ot.Scenarios))
+	scenarios := ot.Result.Scenarios()
+	for _, sc := range ot.Scenarios {
+		if !slices.Contains(scenarios, sc.Name) {
+			continue // Do not create the scenario in the Result only to report it.
+		}
+		r := ot.Result.Scenario(sc.Name)
+		if r.Succeeded()+r.Failed() == 0 {
+			continue
+		}
+		sp, err := ot.summaryReportParams(r)
+		if err != nil {
+			return nil, fmt.Errorf("scenario %s: %w", sc.Name, err)
+		}
+		params = append(params, ScenarioReportParams{
+			Name:                sc.Name,
+			Weight:              sc.Weight,
+			Share:               humanize.CommafWithDigits(float64(r.Succeeded()+r.Failed())/float64(total)*100, 1),
+			SummaryReportParams: *sp,
+		})
+	}
+	return params, nil
+}
+
+func (ot *Otchkiss) summaryReportParams(r *result.Result) (*SummaryReportParams, error) {
+	succeeded, failed := r.Succeeded(), r.Failed()
+	total := succeeded + failed
+	lp, err := latencyReportParams(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SummaryReportParams{
+		TotalRequests:       humanize.Comma(total),
+		Succeeded:           humanize.Comma(succeeded),
+		Failed:              humanize.Comma(failed),
+		ErrorRate:           humanize.CommafWithDigits(float64(failed)/float64(total)*100, 1),
+		RPS:                 humanize.CommafWithDigits(float64(total)/ot.measuredDuration().Seconds(), 1),
+		LatencyReportParams: *lp,
+	}, nil
+}
+
+func latencyReportParams(r *result.Result) (*LatencyReportParams, error) {
+	max, err := r.PercentileLatency(100)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get max latency: %w", err)
+	}
+	min, err := r.PercentileLatency(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get min latency: %w", err)
+	}
+	p99, err := r.PercentileLatency(99)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get 99p latency: %w", err)
+	}
+	p90, err := r.PercentileLatency(90)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get 90p latency: %w", err)
+	}
+	p50, err := r.PercentileLatency(50)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get 50p latency: %w", err)
+	}
+
+	avg, err := r.MeanLatency()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get avg latency: %w", err)
+	}
+
+	return &LatencyReportParams{
+		MaxLatency: humanize.CommafWithDigits(max*1000, 1),
+		MinLatency: humanize.CommafWithDigits(min*1000, 1),
+		AvgLatency: humanize.CommafWithDigits(avg*1000, 1),
+		MedLatency: humanize.CommafWithDigits(p50*1000, 1),
+		Latency99p: humanize.CommafWithDigits(p99*1000, 1),
+		Latency90p: humanize.CommafWithDigits(p90*1000, 1),
+	}, nil
+}
+
+func (ot *Otchkiss) stageReportParams() ([]StageReportParams, error) {
+	results := ot.Result.Stages()
+	params := make([]StageReportParams, 0, len(ot.Setting.Stages))
+	for i, st := range ot.Setting.Stages {
+		var r *result.Result
+		if i < len(results) {
+			r = results[i]
+		}
+		p := StageReportParams{
+			Index:            i,
+			Duration:         st.Duration.String(),
+			TargetRPS:        st.TargetRPS,
+			TargetConcurrent: st.TargetConcurrent,
+		}
+
+		var succeeded, failed int64
+		if r != nil {
+			succeeded, failed = r.Succeeded(), r.Failed()
+		}
+		total := succeeded + failed
+		p.TotalRequests = humanize.Comma(total)
+		p.Succeeded = humanize.Comma(succeeded)
+		p.Failed = humanize.Comma(failed)
+		p.RPS = humanize.CommafWithDigits(float64(total)/st.Duration.Seconds(), 1)
+		if total == 0 {
+			p.ErrorRate, p.MedLatency, p.Latency99p = "0", "-", "-"
+			params = append(params, p)
+			continue
+		}
+
+		p50, err := r.PercentileLatency(50)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get 50p latency of stage %d: %w", i, err)
+		}
+		p99, err := r.PercentileLatency(99)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get 99p latency of stage %d: %w", i, err)
+		}
+		p.ErrorRate = humanize.CommafWithDigits(float64(failed)/float64(total)*100, 1)
+		p.MedLatency = humanize.CommafWithDigits(p50*1000, 1)
+		p.Latency99p = humanize.CommafWithDigits(p99*1000, 1)
+		params = append(params, p)
+	}
+
+	return params, nil
+}
@@ -0,0 +1,399 @@
+package otchkiss
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/ryo-yamaoka/otchkiss/setting"
+)
+
+// JSONSchemaVersion is the version of the schema of JSONReport.
+// It is increased when a field is removed or its meaning is changed, and adding a field does not change it.
+const JSONSchemaVersion = 1
+
+// JSONReportConfig defines the optional contents of the JSON report.
+type JSONReportConfig struct {
+	// Percentiles defines the latency percentiles in the report.
+	// nil means 50, 90, 95 and 99.
+	Percentiles []int
+
+	// HistogramBins defines how many buckets the histogram has.
+	// 0 means 9, the same as the text report.
+	HistogramBins int
+}
+
+// JSONReport is the machine readable report of Otchkiss testing.
+// All the latencies and durations are in seconds, and the rates are in percent.
+type JSONReport struct {
+	SchemaVersion int         `json:"schema_version"`
+	Setting       JSONSetting `json:"setting"`
+
+	// Window is when the first measured request was dispatched and the last one completed, it is nil when nothing is measured.
+	Window *JSONWindow `json:"window,omitempty"`
+
+	Requests JSONRequests `json:"requests"`
+	Latency  *JSONLatency `json:"latency,omitempty"`
+
+	// CorrectedLatency is the latency measured from the intended start times, it is nil unless the test runs in the open model.
+	CorrectedLatency *JSONLatency `json:"corrected_latency,omitempty"`
+
+	Histogram  []JSONBucket     `json:"histogram"`
+	Errors     []JSONErrorGroup `json:"errors"`
+	TimeSeries []JSONPoint      `json:"time_series"`
+	Stages     []JSONStage      `json:"stages,omitempty"`
+	Scenarios  []JSONScenario   `json:"scenarios,omitempty"`
+	Tags       []JSONTag        `json:"tags,omitempty"`
+	Timings    []JSONTiming     `json:"timings,omitempty"`
+
+	// Verdict is the outcome of the thresholds, it is nil when no threshold is specified.
+	Verdict *JSONVerdict `json:"verdict,omitempty"`
+}
+
+type JSONSetting struct {
+	MaxConcurrent    int         `json:"max_concurrent"`
+	MaxRPS           int         `json:"max_rps"`
+	TargetRPS        float64     `json:"target_rps"`
+	RunDuration      float64     `json:"run_duration"`
+	Iterations       int         `json:"iterations"`
+	IterationsPerVU  int         `json:"iterations_per_vu"`
+	MaxDuration      float64     `json:"max_duration"`
+	WarmUpTime       float64     `json:"warm_up_time"`
+	WarmUpRequests   int         `json:"warm_up_requests"`
+	CoolDownTime     float64     `json:"cool_down_time"`
+	OpenModel        bool        `json:"open_model"`
+	VirtualUsers     int         `json:"virtual_users"`
+	Workers          int         `json:"workers"`
+	MaxBacklog       int         `json:"max_backlog"`
+	StageModeLinear  bool        `json:"stage_mode_linear"`
+	Stages           []JSONStage `json:"stages,omitempty"`
+	MeasuredDuration float64     `json:"measured_duration"`
+}
+
+type JSONWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+type JSONRequests struct {
+	Total     int64   `json:"total"`
+	Succeeded int64   `json:"succeeded"`
+	Failed    int64   `json:"failed"`
+	Dropped   int64   `json:"dropped"`
+	Late      int64   `json:"late"`
+	ErrorRate float64 `json:"error_rate"`
+	RPS       float64 `json:"rps"`
+}
+
+type JSONLatency struct {
+	Min         float64          `json:"min"`
+	Max         float64          `json:"max"`
+	Mean        float64          `json:"mean"`
+	Percentiles []JSONPercentile `json:"percentiles"`
+}
+
+type JSONPercentile struct {
+	Percentile int     `json:"percentile"`
+	Latency    float64 `json:"latency"`
+}
+
+type JSONBucket struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int64   `json:"count"`
+}
+
+type JSONErrorGroup struct {
+	Key       string    `json:"key"`
+	Count     int64     `json:"count"`
+	Rate      float64   `json:"rate"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Samples   []string  `json:"samples"`
+}
+
+type JSONPoint struct {
+	Time       time.Time `json:"time"`
+	Offset     float64   `json:"offset"`
+	Interval   float64   `json:"interval"`
+	Succeeded  int64     `json:"succeeded"`
+	Failed     int64     `json:"failed"`
+	RPS        float64   `json:"rps"`
+	Latency50p float64   `json:"latency_50p"`
+	Latency90p float64   `json:"latency_90p"`
+	Latency99p float64   `json:"latency_99p"`
+	MaxLatency float64   `json:"max_latency"`
+}
+
+// JSONSummary is the statistics of a part of the requests, such as a stage, a scenario or a tag.
+type JSONSummary struct {
+	Requests JSONRequests `json:"requests"`
+	Latency  *JSONLatency `json:"latency,omitempty"`
+}
+
+type JSONStage struct {
+	Index            int     `json:"index"`
+	Duration         float64 `json:"duration"`
+	TargetRPS        int     `json:"target_rps"`
+	TargetConcurrent int     `json:"target_concurrent"`
+
+	// Summary is nil in the setting.
+	*JSONSummary `json:",omitempty"`
+}
+
+type JSONScenario struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+	JSONSummary
+}
+
+type JSONTag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	JSONSummary
+}
+
+// JSONTiming is the durations of a part of the requests recorded by Timing.
+type JSONTiming struct {
+	Name    string       `json:"name"`
+	Count   int64        `json:"count"`
+	Latency *JSONLatency `json:"latency"`
+}
+
+type JSONVerdict struct {
+	Passed   bool          `json:"passed"`
+	Outcomes []JSONOutcome `json:"outcomes"`
+}
+
+type JSONOutcome struct {
+	Threshold string  `json:"threshold"`
+	Metric    string  `json:"metric"`
+	Op        string  `json:"op"`
+	Value     float64 `json:"value"`
+	Tag       string  `json:"tag,omitempty"`
+	Observed  float64 `json:"observed"`
+	Passed    bool    `json:"passed"`
+
+	// Error is why the metric could not be observed.
+	Error string `json:"error,omitempty"`
+}
+
+// JSONReport outputs result of Otchkiss testing in JSON with the default config.
+func (ot *Otchkiss) JSONReport() ([]byte, error) {
+	return ot.JSONReportWithConfig(JSONReportConfig{})
+}
+
+// JSONReportWithConfig outputs result of Otchkiss testing in JSON.
+func (ot *Otchkiss) JSONReportWithConfig(c JSONReportConfig) ([]byte, error) {
+	rp, err := ot.jsonReport(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate JSON report: %w", err)
+	}
+	return json.MarshalIndent(rp, "", "  ")
+}
+
+func (ot *Otchkiss) jsonReport(c JSONReportConfig) (*JSONReport, error) {
+	percentiles := c.Percentiles
+	if len(percentiles) == 0 {
+		percentiles = []int{50, 90, 95, 99}
+	}
+	for _, p := range percentiles {
+		if p < 0 || p > 100 {
+			return nil, fmt.Errorf("percentile must be between 0 and 100: %d", p)
+		}
+	}
+	bins := c.HistogramBins
+	if bins == 0 {
+		bins = 9
+	}
+	if bins < 0 {
+		return nil, fmt.Errorf("histogram bins must be >= 0: %d", bins)
+	}
+
+	rp := &JSONReport{
+		SchemaVersion: JSONSchemaVersion,
+		Setting:       ot.jsonSetting(),
+		Errors:        []JSONErrorGroup{},
+		TimeSeries:    []JSONPoint{},
+		Histogram:     []JSONBucket{},
+	}
+
+	summary := ot.jsonSummary(ot.Result, percentiles)
+	rp.Requests, rp.Latency = summary.Requests, summary.Latency
+	rp.Requests.Dropped = ot.Result.Dropped()
+	rp.Requests.Late = ot.Result.Late()
+	if start, end := ot.Result.Window(); !start.IsZero() {
+		rp.Window = &JSONWindow{Start: start, End: end}
+	}
+	if ot.Result.HasCorrected() {
+		rp.CorrectedLatency = jsonLatency(ot.Result.Corrected(), percentiles)
+	}
+
+	for _, b := range ot.Result.HistogramBuckets(bins) {
+		rp.Histogram = append(rp.Histogram, JSONBucket{Min: b.Min, Max: b.Max, Count: b.Count})
+	}
+	for _, g := range ot.Result.ErrorGroups() {
+		samples := make([]string, 0, len(g.Samples))
+		for _, e := range g.Samples {
+			samples = append(samples, e.Error())
+		}
+		rp.Errors = append(rp.Errors, JSONErrorGroup{
+			Key:       g.Key,
+			Count:     g.Count,
+			Rate:      rate(g.Count, rp.Requests.Total),
+			FirstSeen: g.FirstSeen,
+			LastSeen:  g.LastSeen,
+			Samples:   samples,
+		})
+	}
+	for _, p := range ot.Result.TimeSeries() {
+		rp.TimeSeries = append(rp.TimeSeries, JSONPoint{
+			Time:       p.Time,
+			Offset:     p.Offset.Seconds(),
+			Interval:   p.Interval.Seconds(),
+			Succeeded:  p.Succeeded,
+			Failed:     p.Failed,
+			RPS:        p.RPS(),
+			Latency50p: p.Latency50p,
+			Latency90p: p.Latency90p,
+			Latency99p: p.Latency99p,
+			MaxLatency: p.MaxLatency,
+		})
+	}
+
+	stages := ot.Result.Stages()
+	for i, st := range rp.Setting.Stages {
+		var r *result.Result
+		if i < len(stages) {
+			r = stages[i]
+		} else {
+			r, _ = result.WithCapacity(0) // Do not create the stage in the Result only to report it.
+		}
+		s := ot.jsonSummary(r, percentiles)
+		s.Requests.RPS = perSecond(s.Requests.Total, ot.Setting.Stages[i].Duration)
+		st.JSONSummary = s
+		rp.Stages = append(rp.Stages, st)
+	}
+	scenarios := ot.Result.Scenarios()
+	for _, sc := range ot.Scenarios {
+		var r *result.Result
+		if slices.Contains(scenarios, sc.Name) {
+			r = ot.Result.Scenario(sc.Name)
+		} else {
+			r, _ = result.WithCapacity(0) // Do not create the scenario in the Result only to report it.
+		}
+		rp.Scenarios = append(rp.Scenarios, JSONScenario{Name: sc.Name, Weight: sc.Weight, JSONSummary: *ot.jsonSummary(r, percentiles)})
+	}
+	for _, t := range ot.Result.Tags() {
+		rp.Tags = append(rp.Tags, JSONTag{Key: t.Key, Value: t.Value, JSONSummary: *ot.jsonSummary(ot.Result.Tagged(t), percentiles)})
+	}
+	for _, name := range ot.Result.Timings() {
+		r := ot.Result.Timing(name)
+		rp.Timings = append(rp.Timings, JSONTiming{Name: name, Count: r.Succeeded(), Latency: jsonLatency(r, percentiles)})
+	}
+
+	if len(ot.Thresholds) != 0 {
+		v := ot.Verdict()
+		rp.Verdict = &JSONVerdict{Passed: v.Passed, Outcomes: make([]JSONOutcome, 0, len(v.Outcomes))}
+		for _, o := range v.Outcomes {
+			jo := JSONOutcome{
+				Threshold: o.Threshold.String(),
+				Metric:    string(o.Threshold.Metric),
+				Op:        string(o.Threshold.Op),
+				Value:     o.Threshold.Value,
+				Observed:  o.Observed,
+				Passed:    o.Passed,
+			}
+			if o.Threshold.Tag != nil {
+				jo.Tag = o.Threshold.Tag.String()
+			}
+			if o.Err != nil {
+				jo.Error = o.Err.Error()
+			}
+			rp.Verdict.Outcomes = append(rp.Verdict.Outcomes, jo)
+		}
+	}
+	return rp, nil
+}
+
+func (ot *Otchkiss) jsonSetting() JSONSetting {
+	s := ot.Setting
+	js := JSONSetting{
+		MaxConcurrent:    s.MaxConcurrent,
+		MaxRPS:           s.MaxRPS,
+		TargetRPS:        s.TargetRPS(),
+		RunDuration:      s.RunDuration.Seconds(),
+		Iterations:       s.Iterations,
+		IterationsPerVU:  s.IterationsPerVU,
+		MaxDuration:      s.MaxDuration.Seconds(),
+		WarmUpTime:       s.WarmUpTime.Seconds(),
+		WarmUpRequests:   s.WarmUpRequests,
+		CoolDownTime:     s.CoolDownTime.Seconds(),
+		OpenModel:        s.OpenModel,
+		VirtualUsers:     s.VirtualUsers,
+		Workers:          s.Workers,
+		MaxBacklog:       s.MaxBacklog,
+		StageModeLinear:  s.StageMode == setting.StageModeLinear,
+		MeasuredDuration: ot.measuredDuration().Seconds(),
+	}
+	for i, st := range s.Stages {
+		js.Stages = append(js.Stages, JSONStage{
+			Index:            i,
+			Duration:         st.Duration.Seconds(),
+			TargetRPS:        st.TargetRPS,
+			TargetConcurrent: st.TargetConcurrent,
+		})
+	}
+	return js
+}
+
+func (ot *Otchkiss) jsonSummary(r *result.Result, percentiles []int) *JSONSummary {
+	succeeded, failed := r.Succeeded(), r.Failed()
+	total := succeeded + failed
+	return &JSONSummary{
+		Requests: JSONRequests{
+			Total:     total,
+			Succeeded: succeeded,
+			Failed:    failed,
+			ErrorRate: rate(failed, total),
+			RPS:       perSecond(total, ot.measuredDuration()),
+		},
+		Latency: jsonLatency(r, percentiles),
+	}
+}
+
+// jsonLatency returns the latency statistics of r, or nil if r has no request.
+func jsonLatency(r *result.Result, percentiles []int) *JSONLatency {
+	if r.Succeeded()+r.Failed() == 0 {
+		return nil
+	}
+
+	// The errors are impossible because r is not empty and the percentiles have been validated.
+	l := &JSONLatency{Percentiles: make([]JSONPercentile, 0, len(percentiles))}
+	l.Min, _ = r.PercentileLatency(0)
+	l.Max, _ = r.PercentileLatency(100)
+	l.Mean, _ = r.MeanLatency()
+	for _, p := range percentiles {
+		v, _ := r.PercentileLatency(p)
+		l.Percentiles = append(l.Percentiles, JSONPercentile{Percentile: p, Latency: v})
+	}
+	return l
+}
+
+// rate returns n / total in percent, or 0 if total is 0.
+func rate(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100
+}
+
+// perSecond returns n per second over d, or 0 if d is 0, because JSON cannot represent the infinity.
+func perSecond(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
@@ -0,0 +1,163 @@
+package otchkiss
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/ryo-yamaoka/otchkiss/setting"
+	"github.com/ryo-yamaoka/otchkiss/threshold"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONReport(t *testing.T) {
+	t.Parallel()
+
+	r, err := result.WithCapacity(3)
+	require.NoError(t, err)
+	r.AppendSuccess(1)
+	r.AppendSuccess(2)
+	r.AppendFail(3, errors.New("err1"))
+	r.Tagged(result.Tag{Key: "endpoint", Value: "search"}).AppendSuccess(2)
+
+	ot := Otchkiss{
+		Result: r,
+		Setting: &setting.Setting{
+			MaxConcurrent: 1,
+			MaxRPS:        1,
+			RunDuration:   2 * time.Second,
+		},
+		Thresholds: []threshold.Threshold{
+			{Metric: threshold.MaxLatency, Op: threshold.Less, Value: 2.5},
+		},
+	}
+
+	b, err := ot.JSONReport()
+	require.NoError(t, err)
+	var rp JSONReport
+	require.NoError(t, json.Unmarshal(b, &rp))
+
+	assert.Equal(t, JSONSchemaVersion, rp.SchemaVersion)
+	assert.Equal(t, 2.0, rp.Setting.RunDuration)
+	assert.Equal(t, 2.0, rp.Setting.MeasuredDuration)
+	assert.Nil(t, rp.Window)
+	assert.InDelta(t, 33.3, rp.Requests.ErrorRate, 0.1)
+	rp.Requests.ErrorRate = 0
+	assert.Equal(t, JSONRequests{Total: 3, Succeeded: 2, Failed: 1, RPS: 1.5}, rp.Requests)
+
+	require.NotNil(t, rp.Latency)
+	assert.Equal(t, 1.0, rp.Latency.Min)
+	assert.Equal(t, 3.0, rp.Latency.Max)
+	assert.Equal(t, 2.0, rp.Latency.Mean)
+	assert.Equal(t, []int{50, 90, 95, 99}, percentilesOf(rp.Latency.Percentiles))
+	assert.Nil(t, rp.CorrectedLatency)
+
+	require.Len(t, rp.Histogram, 9)
+	assert.Equal(t, int64(1), rp.Histogram[0].Count)
+	assert.Equal(t, int64(1), rp.Histogram[8].Count)
+
+	require.Len(t, rp.Errors, 1)
+	assert.Equal(t, "err1", rp.Errors[0].Key)
+	assert.Equal(t, []string{"err1"}, rp.Errors[0].Samples)
+
+	require.Len(t, rp.Tags, 1)
+	assert.Equal(t, "search", rp.Tags[0].Value)
+	assert.Equal(t, int64(1), rp.Tags[0].Requests.Total)
+
+	require.NotNil(t, rp.Verdict)
+	assert.False(t, rp.Verdict.Passed)
+	require.Len(t, rp.Verdict.Outcomes, 1)
+	assert.Equal(t, "max < 2.5s", rp.Verdict.Outcomes[0].Threshold)
+	assert.Equal(t, 3.0, rp.Verdict.Outcomes[0].Observed)
+}
+
+func TestJSONReportWithConfig(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		config          JSONReportConfig
+		wantPercentiles []int
+		wantBuckets     int
+		wantError       assert.ErrorAssertionFunc
+	}{
+		"custom": {
+			config:          JSONReportConfig{Percentiles: []int{75, 100}, HistogramBins: 3},
+			wantPercentiles: []int{75, 100},
+			wantBuckets:     3,
+			wantError:       assert.NoError,
+		},
+		"invalid percentile": {
+			config:    JSONReportConfig{Percentiles: []int{101}},
+			wantError: assert.Error,
+		},
+		"invalid bins": {
+			config:    JSONReportConfig{HistogramBins: -1},
+			wantError: assert.Error,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tn, tc := tn, tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := result.WithCapacity(2)
+			require.NoError(t, err)
+			r.AppendSuccess(1)
+			r.AppendSuccess(2)
+			ot := Otchkiss{Result: r, Setting: &setting.Setting{}}
+
+			b, err := ot.JSONReportWithConfig(tc.config)
+			tc.wantError(t, err)
+			if err != nil {
+				return
+			}
+			var rp JSONReport
+			require.NoError(t, json.Unmarshal(b, &rp))
+			assert.Equal(t, tc.wantPercentiles, percentilesOf(rp.Latency.Percentiles))
+			assert.Len(t, rp.Histogram, tc.wantBuckets)
+			assert.Equal(t, 0.0, rp.Requests.RPS, "RPS must be 0 without the duration")
+		})
+	}
+}
+
+func TestJSONReportEmpty(t *testing.T) {
+	t.Parallel()
+
+	r, err := result.New()
+	require.NoError(t, err)
+	ot := Otchkiss{
+		Result: r,
+		Setting: &setting.Setting{
+			Stages: []setting.Stage{{Duration: time.Second, TargetRPS: 10}},
+		},
+		Scenarios: []Scenario{{Name: "search", Weight: 1}},
+	}
+
+	b, err := ot.JSONReport()
+	require.NoError(t, err)
+	var rp JSONReport
+	require.NoError(t, json.Unmarshal(b, &rp))
+
+	assert.Nil(t, rp.Latency)
+	assert.Empty(t, rp.Errors)
+	assert.Nil(t, rp.Verdict)
+	require.Len(t, rp.Stages, 1)
+	assert.Equal(t, int64(0), rp.Stages[0].Requests.Total)
+	assert.Empty(t, r.Stages(), "the report must not create the stage")
+	require.Len(t, rp.Scenarios, 1)
+	assert.Equal(t, int64(0), rp.Scenarios[0].Requests.Total)
+	assert.Empty(t, r.Scenarios(), "the report must not create the scenario")
+	assert.False(t, r.HasCorrected())
+}
+
+func percentilesOf(ps []JSONPercentile) []int {
+	var out []int
+	for _, p := range ps {
+		out = append(out, p.Percentile)
+	}
+	return out
+}
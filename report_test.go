@@ -0,0 +1,223 @@
+package otchkiss
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/ryo-yamaoka/otchkiss/setting"
+	"github.com/ryo-yamaoka/otchkiss/threshold"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		setting    *setting.Setting
+		templ      string
+		wantReport string
+		wantError  assert.ErrorAssertionFunc
+	}{
+		"default": {
+			setting: &setting.Setting{
+				MaxConcurrent: 1,
+				MaxRPS:        1,
+				RunDuration:   2 * time.Second,
+				WarmUpTime:    3 * time.Second,
+			},
+			templ:      defaultReportTemplate,
+			wantReport: "\n[Setting]\n* warm up time:   3s\n* duration:       2s\n* max concurrent: 1\n* max RPS:        1\n\n[Request]\n* total:      3\n* succeeded:  2\n* failed:     1\n* error rate: 33.3 %\n* RPS:        1.5\n* target RPS: 1 (achieved: 150 %)\n\n[Errors]\n* err1: 1 (33.3 %)\n\n[Latency]\n* max: 3,000 ms\n* min: 1,000 ms\n* avg: 2,000 ms\n* med: 1,000 ms\n* 99th percentile: 2,000 ms\n* 90th percentile: 2,000 ms\n\n[Histogram]\n1s-1.222222222s            33.3%  █████████████████████████▏  1\n1.222222222s-1.444444444s  0%     ▏                           \n1.444444444s-1.666666666s  0%     ▏                           \n1.666666666s-1.888888888s  0%     ▏                           \n1.888888888s-2.111111111s  33.3%  █████████████████████████▏  1\n2.111111111s-2.333333333s  0%     ▏                           \n2.333333333s-2.555555555s  0%     ▏                           \n2.555555555s-2.777777777s  0%     ▏                           \n2.777777777s-3s            33.3%  █████████████████████████▏  1\n\n[Time Series]\nRPS:\n3 ┤█\n  │█\n  │█\n  │█\n0 ┤█\n   0s 1s\n\n99th percentile (ms):\n1,993.6 ┤█\n        │█\n        │█\n        │█\n      0 ┤█\n         0s 1s\n\n",
+			wantError:  assert.NoError,
+		},
+		"user format": {
+			setting: &setting.Setting{
+				WarmUpTime: 3 * time.Second,
+			},
+			templ:      "{{.WarmUpTime}}",
+			wantReport: "3s",
+			wantError:  assert.NoError,
+		},
+		"empty": {
+			setting:    &setting.Setting{},
+			templ:      "",
+			wantReport: "",
+			wantError:  assert.Error,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tn, tc := tn, tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := result.WithCapacity(3)
+			require.NoError(t, err)
+			ot := Otchkiss{
+				Result:  r,
+				Setting: tc.setting,
+			}
+			ot.Result.AppendSuccess(1)
+			ot.Result.AppendSuccess(2)
+			ot.Result.AppendFail(3, errors.New("err1"))
+
+			report, err := ot.TemplateReport(tc.templ)
+			tc.wantError(t, err)
+			diff := cmp.Diff(tc.wantReport, report)
+			assert.Empty(t, diff)
+
+			if tn == "default" {
+				report, err := ot.Report()
+				tc.wantError(t, err)
+				diff := cmp.Diff(tc.wantReport, report)
+				assert.Empty(t, diff)
+			}
+		})
+	}
+}
+
+func TestReportStages(t *testing.T) {
+	t.Parallel()
+
+	r, err := result.WithCapacity(3)
+	require.NoError(t, err)
+	ot := Otchkiss{
+		Result: r,
+		Setting: &setting.Setting{
+			Stages: []setting.Stage{
+				{Duration: 1 * time.Second, TargetRPS: 10, TargetConcurrent: 1},
+				{Duration: 2 * time.Second, TargetRPS: 20, TargetConcurrent: 2},
+			},
+		},
+	}
+	ot.Result.Stage(0).AppendSuccess(1)
+	ot.Result.Stage(0).AppendFail(3, errors.New("err1"))
+	ot.Result.AppendSuccess(1)
+
+	const templ = `{{.Duration}}{{range .Stages}}
+{{.Index}} {{.Duration}} {{.TargetRPS}} {{.TargetConcurrent}} {{.TotalRequests}} {{.Failed}} {{.ErrorRate}} {{.RPS}} {{.MedLatency}} {{.Latency99p}}{{end}}`
+	const want = `3s
+0 1s 10 1 2 1 50 2 1,000 1,000
+1 2s 20 2 0 0 0 0 - -`
+
+	report, err := ot.TemplateReport(templ)
+	require.NoError(t, err)
+	assert.Equal(t, want, report)
+}
+
+func TestReportWindow(t *testing.T) {
+	t.Parallel()
+
+	r, err := result.WithCapacity(2)
+	require.NoError(t, err)
+	ot := Otchkiss{
+		Result:  r,
+		Setting: &setting.Setting{MaxRPS: 1, RunDuration: 10 * time.Second},
+	}
+	base := time.Now()
+	ot.Result.AppendSuccess(1)
+	ot.Result.ExtendWindow(base, base.Add(1*time.Second))
+	ot.Result.AppendSuccess(1)
+	ot.Result.ExtendWindow(base.Add(3*time.Second), base.Add(4*time.Second))
+
+	report, err := ot.TemplateReport("{{.Duration}} {{.RPS}} {{.TargetRPS}} {{.AchievedRPS}}")
+	require.NoError(t, err)
+	assert.Equal(t, "4s 0.5 1 50", report, "RPS must be computed from the window instead of RunDuration")
+}
+
+func TestReportThresholds(t *testing.T) {
+	t.Parallel()
+
+	r, err := result.WithCapacity(2)
+	require.NoError(t, err)
+	ot := Otchkiss{
+		Result:  r,
+		Setting: &setting.Setting{RunDuration: 1 * time.Second},
+		Thresholds: []threshold.Threshold{
+			{Metric: threshold.MaxLatency, Op: threshold.Less, Value: 0.25},
+			{Metric: threshold.ErrorRate, Op: threshold.Less, Value: 60},
+		},
+	}
+	ot.Result.AppendSuccess(0.1)
+	ot.Result.AppendFail(0.5, errors.New("err1"))
+
+	report, err := ot.Report()
+	require.NoError(t, err)
+	assert.Contains(t, report, "\n[Thresholds: FAIL]\n* FAIL: max < 250ms (observed: 500ms)\n* PASS: error_rate < 60% (observed: 50%)\n")
+	assert.False(t, ot.Verdict().Passed)
+}
+
+func TestReportCorrectedLatency(t *testing.T) {
+	t.Parallel()
+
+	r, err := result.WithCapacity(2)
+	require.NoError(t, err)
+	ot := Otchkiss{
+		Result:  r,
+		Setting: &setting.Setting{OpenModel: true},
+	}
+	ot.Result.AppendSuccess(1)
+	ot.Result.AppendSuccess(2)
+
+	const templ = `{{with .CorrectedLatency}}{{.MinLatency}} {{.MaxLatency}}{{else}}none{{end}}`
+	report, err := ot.TemplateReport(templ)
+	require.NoError(t, err)
+	assert.Equal(t, "none", report)
+	assert.False(t, ot.Result.HasCorrected())
+
+	ot.Result.Corrected().AppendSuccess(1.5)
+	ot.Result.Corrected().AppendSuccess(4)
+	report, err = ot.TemplateReport(templ)
+	require.NoError(t, err)
+	assert.Equal(t, "1,500 4,000", report)
+}
+
+func TestReportErrors(t *testing.T) {
+	t.Parallel()
+
+	r, err := result.New()
+	require.NoError(t, err)
+	ot := Otchkiss{
+		Result:  r,
+		Setting: &setting.Setting{RunDuration: 1 * time.Second},
+	}
+	for i := 0; i < 7; i++ {
+		for j := 0; j <= i; j++ {
+			ot.Result.AppendFail(1, fmt.Errorf("err%d", i))
+		}
+	}
+
+	const templ = `{{range .Errors}}{{.Key}} {{.Count}} {{.Rate}}
+{{end}}{{.OtherErrorKinds}}`
+	const want = `err6 7 25
+err5 6 21.4
+err4 5 17.8
+err3 4 14.2
+err2 3 10.7
+2`
+	report, err := ot.TemplateReport(templ)
+	require.NoError(t, err)
+	assert.Equal(t, want, report)
+}
+
+func TestReportScenariosNotRecorded(t *testing.T) {
+	t.Parallel()
+
+	r, err := result.WithCapacity(1)
+	require.NoError(t, err)
+	ot := Otchkiss{
+		Result:    r,
+		Setting:   &setting.Setting{},
+		Scenarios: []Scenario{{Name: "search", Weight: 1}},
+	}
+	ot.Result.AppendSuccess(1)
+
+	report, err := ot.TemplateReport(`{{range .Scenarios}}{{.Name}}{{end}}`)
+	require.NoError(t, err)
+	assert.Empty(t, report)
+	assert.Empty(t, r.Scenarios(), "the report must not create the scenario")
+}
@@ -0,0 +1,155 @@
+package result
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// errorSamples defines how many errors each ErrorGroup keeps as is.
+const errorSamples = 3
+
+// Classifier returns the key to group err by.
+type Classifier func(err error) string
+
+// DefaultClassifier groups errors by their message.
+func DefaultClassifier(err error) string {
+	return err.Error()
+}
+
+// ClassifyIs returns Classifier which groups errors matching target by errors.Is as key, and classifies the others by next.
+// If next is nil, DefaultClassifier is used.
+func ClassifyIs(target error, key string, next Classifier) Classifier {
+	if next == nil {
+		next = DefaultClassifier
+	}
+	return func(err error) string {
+		if errors.Is(err, target) {
+			return key
+		}
+		return next(err)
+	}
+}
+
+// ClassifyAs returns Classifier which groups errors matching T by errors.As as key, and classifies the others by next.
+// If next is nil, DefaultClassifier is used.
+func ClassifyAs[T error](key string, next Classifier) Classifier {
+	if next == nil {
+		next = DefaultClassifier
+	}
+	return func(err error) string {
+		var target T
+		if errors.As(err, &target) {
+			return key
+		}
+		return next(err)
+	}
+}
+
+// ErrorGroup represents the errors which are classified into the same key.
+type ErrorGroup struct {
+	Key       string
+	Count     int64
+	FirstSeen time.Time
+	LastSeen  time.Time
+
+	// Samples are the first few errors of the group.
+	Samples []error
+
+	seq int
+}
+
+// errorGroups records errors grouped by the classifier.
+type errorGroups struct {
+	classifier Classifier
+	groups     map[string]*ErrorGroup
+}
+
+func (eg *errorGroups) add(err error, at time.Time) {
+	classifier := eg.classifier
+	if classifier == nil {
+		classifier = DefaultClassifier
+	}
+	if eg.groups == nil {
+		eg.groups = make(map[string]*ErrorGroup)
+	}
+
+	key := classifier(err)
+	g, ok := eg.groups[key]
+	if !ok {
+		g = &ErrorGroup{Key: key, FirstSeen: at, seq: len(eg.groups)}
+		eg.groups[key] = g
+	}
+	g.Count++
+	g.LastSeen = at
+	if len(g.Samples) < errorSamples {
+		g.Samples = append(g.Samples, err)
+	}
+}
+
+// sorted returns the copies of the groups in descending order of the count, and the first seen one comes first in the same count.
+func (eg *errorGroups) sorted() []ErrorGroup {
+	groups := make([]ErrorGroup, 0, len(eg.groups))
+	for _, g := range eg.groups {
+		c := *g
+		c.Samples = append([]error(nil), g.Samples...)
+		groups = append(groups, c)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].seq < groups[j].seq
+	})
+	return groups
+}
+
+// SetErrorClassifier changes how failures are grouped (default: DefaultClassifier).
+// It should be called before anything is recorded, because already grouped errors are not classified again.
+func (r *Result) SetErrorClassifier(c Classifier) {
+	r.errorsMu.Lock()
+	defer r.errorsMu.Unlock()
+	r.errors.classifier = c
+}
+
+// ErrorGroups returns the groups of the errors in descending order of the count.
+func (r *Result) ErrorGroups() []ErrorGroup {
+	r.errorsMu.Lock()
+	defer r.errorsMu.Unlock()
+	return r.errors.sorted()
+}
+
+// Error returns the keys of the error groups joined by comma, with the count when the group has more than one error.
+func (r *Result) Error() string {
+	const delimiter = ", "
+	var sb strings.Builder
+	for i, g := range r.ErrorGroups() {
+		if i != 0 {
+			sb.WriteString(delimiter)
+		}
+		sb.WriteString(g.Key)
+		if g.Count > 1 {
+			fmt.Fprintf(&sb, " (x%d)", g.Count)
+		}
+	}
+	return sb.String()
+}
+
+// Errors returns the sample errors of each group, not every recorded error.
+func (r *Result) Errors() []error {
+	var errs []error
+	for _, g := range r.ErrorGroups() {
+		errs = append(errs, g.Samples...)
+	}
+	return errs
+}
+
+func (r *Result) appendError(err error) {
+	at := time.Now()
+
+	r.errorsMu.Lock()
+	defer r.errorsMu.Unlock()
+	r.errors.add(err, at)
+}
@@ -0,0 +1,105 @@
+package result
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorGroups(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		classifier Classifier
+		errs       []error
+		wantKeys   []string
+		wantCounts []int64
+		wantError  string
+	}{
+		"default": {
+			classifier: nil,
+			errs: []error{
+				errors.New("err1"),
+				errors.New("err2"),
+				errors.New("err2"),
+				errors.New("err3"),
+				errors.New("err2"),
+				errors.New("err3"),
+			},
+			wantKeys:   []string{"err2", "err3", "err1"},
+			wantCounts: []int64{3, 2, 1},
+			wantError:  "err2 (x3), err3 (x2), err1",
+		},
+		"classify is and as": {
+			classifier: ClassifyIs(context.DeadlineExceeded, "timeout", ClassifyAs[*fs.PathError]("path", nil)),
+			errs: []error{
+				fmt.Errorf("request 1: %w", context.DeadlineExceeded),
+				&fs.PathError{Op: "open", Path: "a", Err: fs.ErrNotExist},
+				fmt.Errorf("request 2: %w", context.DeadlineExceeded),
+				fmt.Errorf("wrapped: %w", &fs.PathError{Op: "open", Path: "b", Err: os.ErrPermission}),
+				errors.New("other"),
+			},
+			wantKeys:   []string{"timeout", "path", "other"},
+			wantCounts: []int64{2, 2, 1},
+			wantError:  "timeout (x2), path (x2), other",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := New()
+			require.NoError(t, err)
+			res.SetErrorClassifier(tc.classifier)
+			before := time.Now()
+			for _, err := range tc.errs {
+				res.AppendFail(0, err)
+			}
+
+			groups := res.ErrorGroups()
+			var keys []string
+			var counts []int64
+			for _, g := range groups {
+				keys = append(keys, g.Key)
+				counts = append(counts, g.Count)
+				assert.False(t, g.FirstSeen.Before(before))
+				assert.False(t, g.LastSeen.Before(g.FirstSeen))
+				assert.Len(t, g.Samples, int(min(g.Count, errorSamples)))
+			}
+			assert.Equal(t, tc.wantKeys, keys)
+			assert.Equal(t, tc.wantCounts, counts)
+			assert.Equal(t, tc.wantError, res.Error())
+			assert.Equal(t, int64(len(tc.errs)), res.Failed())
+		})
+	}
+}
+
+func TestErrorGroupsBounded(t *testing.T) {
+	t.Parallel()
+
+	res, err := New()
+	require.NoError(t, err)
+	res.SetErrorClassifier(ClassifyIs(context.DeadlineExceeded, "timeout", nil))
+	for i := 0; i < 10000; i++ {
+		res.AppendFail(0, fmt.Errorf("request %d: %w", i, context.DeadlineExceeded))
+	}
+
+	assert.Equal(t, "timeout (x10000)", res.Error())
+	errs := res.Errors()
+	require.Len(t, errs, errorSamples)
+	assert.EqualError(t, errs[0], "request 0: context deadline exceeded")
+
+	res.Stage(0).AppendFail(0, fmt.Errorf("stage: %w", context.DeadlineExceeded))
+	groups := res.Stage(0).ErrorGroups()
+	require.Len(t, groups, 1)
+	assert.Equal(t, "timeout", groups[0].Key, "stage results inherit the classifier")
+}
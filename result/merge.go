@@ -0,0 +1,184 @@
+package result
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Merge adds everything recorded in other to r, as if r had recorded the requests of other as well.
+// It is for combining the runs of the same test from several processes or machines, for example loaded by UnmarshalBinary.
+//
+// The counts, the error groups, the stages, the tags, the scenarios and the timings are merged as they are,
+// and the latencies are merged as distributions, so the percentiles are the ones of all the requests rather than the average.
+// The time series keeps the intervals of r, and each interval of other is added to the one of r in which it begins,
+// so a request of other may move to the previous interval if the origins differ.
+// The latencies in the histogram cannot be merged into r which keeps every latency, nor into the histogram of a different precision.
+func (r *Result) Merge(other *Result) error {
+	if other == r {
+		return errors.New("cannot merge a result into itself")
+	}
+	if err := r.canMerge(other); err != nil {
+		return err
+	}
+	r.merge(other)
+	return nil
+}
+
+func (r *Result) canMerge(other *Result) error {
+	r.latenciesMu.Lock()
+	dst := r.latencies
+	r.latenciesMu.Unlock()
+	other.latenciesMu.Lock()
+	src := other.latencies
+	other.latenciesMu.Unlock()
+
+	switch d := dst.(type) {
+	case *rawStore:
+		if _, ok := src.(*histStore); ok {
+			return errors.New("cannot merge the histogram into the raw latencies")
+		}
+	case *histStore:
+		if s, ok := src.(*histStore); ok && s.precision != d.precision {
+			return fmt.Errorf("cannot merge the histogram of precision %v into %v", s.precision, d.precision)
+		}
+	}
+
+	other.seriesMu.Lock()
+	srcInterval, srcLen := other.series.interval, len(other.series.buckets)
+	other.seriesMu.Unlock()
+	r.seriesMu.Lock()
+	dstInterval, dstLen := r.series.interval, len(r.series.buckets)
+	r.seriesMu.Unlock()
+
+	if dstLen != 0 && srcLen != 0 && srcInterval != dstInterval {
+		return fmt.Errorf("cannot merge the time series of interval %s into %s", srcInterval, dstInterval)
+	}
+	return nil
+}
+
+// merge adds other to r, the children of r are created as needed.
+// other is copied part by part, so that the locks of r and other are never held together.
+func (r *Result) merge(other *Result) {
+	atomic.AddInt64(&r.succeeded, other.Succeeded())
+	atomic.AddInt64(&r.failed, other.Failed())
+	atomic.AddInt64(&r.dropped, other.Dropped())
+	atomic.AddInt64(&r.late, other.Late())
+
+	other.latenciesMu.Lock()
+	latencies := snapshotStore(other.latencies).restore()
+	other.latenciesMu.Unlock()
+	r.latenciesMu.Lock()
+	r.latencies.merge(latencies)
+	r.latenciesMu.Unlock()
+
+	groups := other.ErrorGroups()
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].seq < groups[j].seq // Keep the first seen order of other for the new groups.
+	})
+	r.errorsMu.Lock()
+	for _, g := range groups {
+		r.errors.merge(g)
+	}
+	r.errorsMu.Unlock()
+
+	other.seriesMu.Lock()
+	origin, interval := other.series.origin, other.series.interval
+	buckets := make([]*seriesBucket, 0, len(other.series.buckets))
+	for _, b := range other.series.buckets {
+		if b != nil {
+			b = &seriesBucket{succeeded: b.succeeded, failed: b.failed, latencies: snapshotStore(b.latencies).restore()}
+		}
+		buckets = append(buckets, b)
+	}
+	other.seriesMu.Unlock()
+	r.seriesMu.Lock()
+	r.series.merge(origin, interval, buckets)
+	r.seriesMu.Unlock()
+
+	for i, st := range other.Stages() {
+		r.Stage(i).merge(st)
+	}
+	other.correctedMu.Lock()
+	corrected := other.corrected
+	other.correctedMu.Unlock()
+	if corrected != nil {
+		r.Corrected().merge(corrected)
+	}
+	for _, t := range other.Tags() {
+		r.Tagged(t).merge(other.Tagged(t))
+	}
+	for _, name := range other.Scenarios() {
+		r.Scenario(name).merge(other.Scenario(name))
+	}
+	for _, name := range other.Timings() {
+		r.Timing(name).merge(other.Timing(name))
+	}
+
+	if start, end := other.Window(); !start.IsZero() {
+		r.ExtendWindow(start, end)
+	}
+}
+
+// merge adds the group g to the groups, the samples are kept up to errorSamples.
+func (eg *errorGroups) merge(g ErrorGroup) {
+	if eg.groups == nil {
+		eg.groups = make(map[string]*ErrorGroup)
+	}
+	mg, ok := eg.groups[g.Key]
+	if !ok {
+		mg = &ErrorGroup{Key: g.Key, FirstSeen: g.FirstSeen, LastSeen: g.LastSeen, seq: len(eg.groups)}
+		eg.groups[g.Key] = mg
+	}
+	mg.Count += g.Count
+	if g.FirstSeen.Before(mg.FirstSeen) {
+		mg.FirstSeen = g.FirstSeen
+	}
+	if g.LastSeen.After(mg.LastSeen) {
+		mg.LastSeen = g.LastSeen
+	}
+	for _, err := range g.Samples {
+		if len(mg.Samples) >= errorSamples {
+			break
+		}
+		mg.Samples = append(mg.Samples, err)
+	}
+}
+
+// merge adds the buckets of the time series which begins at origin.
+// The origin moves back by whole intervals when the buckets begin earlier, so the recorded buckets keep their times.
+func (ts *timeSeries) merge(origin time.Time, interval time.Duration, buckets []*seriesBucket) {
+	if len(buckets) == 0 || ts.newStore == nil {
+		return // Nothing to merge, or r is a child which does not record the time series.
+	}
+	if len(ts.buckets) == 0 {
+		ts.interval = interval
+		ts.origin = origin
+	}
+	if origin.Before(ts.origin) {
+		shift := int((ts.origin.Sub(origin) + ts.interval - 1) / ts.interval)
+		ts.buckets = append(make([]*seriesBucket, shift), ts.buckets...)
+		ts.origin = ts.origin.Add(-time.Duration(shift) * ts.interval)
+	}
+
+	for i, b := range buckets {
+		if b == nil {
+			continue
+		}
+		at := origin.Add(time.Duration(i) * interval)
+		idx := int(at.Sub(ts.origin) / ts.interval)
+		for len(ts.buckets) <= idx {
+			ts.buckets = append(ts.buckets, nil)
+		}
+		mb := ts.buckets[idx]
+		if mb == nil {
+			mb = &seriesBucket{latencies: ts.newStore()}
+			ts.buckets[idx] = mb
+		}
+		mb.succeeded += b.succeeded
+		mb.failed += b.failed
+		mb.latencies.merge(b.latencies)
+	}
+}
@@ -0,0 +1,238 @@
+package result
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		newResult func() (*Result, error)
+	}{
+		"raw": {
+			newResult: func() (*Result, error) { return WithCapacity(0) },
+		},
+		"histogram": {
+			newResult: New,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			// record records the i-th request of the test.
+			record := func(r *Result, i int) {
+				v := float64(i%37) * 0.003
+				switch {
+				case i%7 == 0:
+					r.AppendFail(v, fmt.Errorf("err%d", i%3))
+				default:
+					r.AppendSuccess(v)
+				}
+				r.Stage(i % 2).AppendSuccess(v)
+				r.Tagged(Tag{Key: "n", Value: fmt.Sprint(i % 3)}).AppendSuccess(v)
+				r.Scenario(fmt.Sprint(i % 4)).AppendSuccess(v)
+				r.Timing(fmt.Sprint("t", i%2)).AppendSuccess(v / 2)
+				if i%5 == 0 {
+					r.AddDropped()
+				}
+			}
+
+			combined, err := tc.newResult()
+			require.NoError(t, err)
+			a, err := tc.newResult()
+			require.NoError(t, err)
+			b, err := tc.newResult()
+			require.NoError(t, err)
+			for i := 0; i < 1000; i++ {
+				record(combined, i)
+				if i%3 == 0 {
+					record(a, i)
+				} else {
+					record(b, i)
+				}
+			}
+			base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			a.ExtendWindow(base, base.Add(2*time.Second))
+			b.ExtendWindow(base.Add(time.Second), base.Add(3*time.Second))
+
+			require.NoError(t, a.Merge(b))
+			assertSameResult(t, combined, a)
+			assert.Equal(t, 3*time.Second, a.Elapsed())
+
+			var total int64
+			for _, p := range a.TimeSeries() {
+				total += p.Succeeded + p.Failed
+			}
+			assert.Equal(t, int64(1000), total)
+
+			require.Len(t, a.Stages(), 2)
+			for i := range a.Stages() {
+				assertSameResult(t, combined.Stage(i), a.Stage(i))
+			}
+			require.Equal(t, combined.Tags(), a.Tags())
+			for _, tag := range a.Tags() {
+				assertSameResult(t, combined.Tagged(tag), a.Tagged(tag))
+			}
+			assert.ElementsMatch(t, combined.Scenarios(), a.Scenarios())
+			for _, name := range a.Scenarios() {
+				assertSameResult(t, combined.Scenario(name), a.Scenario(name))
+			}
+			assert.ElementsMatch(t, combined.Timings(), a.Timings())
+			for _, name := range a.Timings() {
+				assertSameResult(t, combined.Timing(name), a.Timing(name))
+			}
+		})
+	}
+}
+
+func assertSameResult(t *testing.T, want, got *Result) {
+	t.Helper()
+
+	assert.Equal(t, want.Succeeded(), got.Succeeded())
+	assert.Equal(t, want.Failed(), got.Failed())
+	assert.Equal(t, want.Dropped(), got.Dropped())
+	for _, p := range []int{0, 10, 50, 90, 99, 100} {
+		wv, err := want.PercentileLatency(p)
+		require.NoError(t, err)
+		gv, err := got.PercentileLatency(p)
+		require.NoError(t, err)
+		assert.Equal(t, wv, gv, "p%d", p)
+	}
+	wm, _ := want.MeanLatency()
+	gm, _ := got.MeanLatency()
+	assert.InDelta(t, wm, gm, 1e-9)
+	assert.Equal(t, want.HistogramBuckets(9), got.HistogramBuckets(9))
+
+	wg, gg := want.ErrorGroups(), got.ErrorGroups()
+	require.Len(t, gg, len(wg))
+	for i := range wg {
+		assert.Equal(t, wg[i].Key, gg[i].Key)
+		assert.Equal(t, wg[i].Count, gg[i].Count)
+		assert.Len(t, gg[i].Samples, len(wg[i].Samples))
+	}
+}
+
+func TestMergeErrorGroups(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	eg := errorGroups{}
+	eg.add(errors.New("a"), base.Add(time.Second))
+	eg.merge(ErrorGroup{Key: "a", Count: 3, FirstSeen: base, LastSeen: base.Add(2 * time.Second), Samples: []error{errors.New("a"), errors.New("a"), errors.New("a")}})
+	eg.merge(ErrorGroup{Key: "b", Count: 1, FirstSeen: base, LastSeen: base, Samples: []error{errors.New("b")}})
+
+	groups := eg.sorted()
+	require.Len(t, groups, 2)
+	assert.Equal(t, "a", groups[0].Key)
+	assert.Equal(t, int64(4), groups[0].Count)
+	assert.Equal(t, base, groups[0].FirstSeen)
+	assert.Equal(t, base.Add(2*time.Second), groups[0].LastSeen)
+	assert.Len(t, groups[0].Samples, errorSamples)
+	assert.Equal(t, "b", groups[1].Key)
+}
+
+func TestMergeTimeSeries(t *testing.T) {
+	t.Parallel()
+
+	origin := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ts := newTimeSeries(func() store { return newRawStore(0) })
+	ts.add(origin, 0.1, false)
+	ts.add(origin.Add(1500*time.Millisecond), 0.2, false)
+
+	other := newTimeSeries(func() store { return newRawStore(0) })
+	other.add(origin.Add(-1200*time.Millisecond), 0.3, true) // Begins in the interval 2s before origin
+	other.add(origin.Add(1000*time.Millisecond), 0.4, false)
+	ts.merge(other.origin, other.interval, other.buckets)
+
+	points := ts.points()
+	assert.Equal(t, origin.Add(-2*time.Second), ts.origin, "the recorded buckets must keep their times")
+	require.Len(t, points, 4)
+	assert.Equal(t, int64(1), points[0].Failed)
+	assert.Equal(t, int64(0), points[1].Succeeded+points[1].Failed)
+	assert.Equal(t, int64(2), points[2].Succeeded, "the interval of other from 0.8s is merged into the one from 0s")
+	assert.Equal(t, int64(1), points[3].Succeeded)
+	assert.Equal(t, 0.2, points[3].MaxLatency)
+}
+
+func TestMergeMarshalBinary(t *testing.T) {
+	t.Parallel()
+
+	origin := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a, err := New()
+	require.NoError(t, err)
+	a.series.add(origin, 0.1, false)
+	b, err := New()
+	require.NoError(t, err)
+	b.series.add(origin.Add(-3*time.Second), 0.2, false) // Started 3 intervals earlier
+	b.series.add(origin.Add(5*time.Second), 0.3, false)
+
+	require.NoError(t, a.Merge(b))
+	data, err := a.MarshalBinary()
+	require.NoError(t, err)
+	got := new(Result)
+	require.NoError(t, got.UnmarshalBinary(data))
+	assert.Equal(t, a.TimeSeries(), got.TimeSeries())
+	assert.Len(t, got.TimeSeries(), 9)
+}
+
+func TestMergeEachOther(t *testing.T) {
+	t.Parallel()
+
+	a, err := New()
+	require.NoError(t, err)
+	b, err := New()
+	require.NoError(t, err)
+	a.AppendSuccess(0.1)
+	b.AppendSuccess(0.2)
+
+	// The locks of the two results are never held together, so merging them into each other at once does not deadlock.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, a.Merge(b))
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, b.Merge(a))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMergeInvalid(t *testing.T) {
+	t.Parallel()
+
+	raw, err := WithCapacity(0)
+	require.NoError(t, err)
+	hist, err := WithHistogram(0.01)
+	require.NoError(t, err)
+	coarse, err := WithHistogram(0.1)
+	require.NoError(t, err)
+	seconds, err := WithCapacity(0)
+	require.NoError(t, err)
+	minutes, err := WithCapacity(0)
+	require.NoError(t, err)
+	require.NoError(t, minutes.SetTimeSeriesInterval(time.Minute))
+	seconds.AppendSuccess(0.1)
+	minutes.AppendSuccess(0.1)
+
+	assert.Error(t, raw.Merge(raw), "itself")
+	assert.Error(t, raw.Merge(hist), "histogram into raw")
+	assert.Error(t, hist.Merge(coarse), "different precision")
+	assert.Error(t, seconds.Merge(minutes), "different interval")
+	assert.NoError(t, hist.Merge(seconds), "raw into histogram")
+	assert.Equal(t, int64(1), hist.Succeeded())
+}
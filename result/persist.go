@@ -0,0 +1,280 @@
+package result
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// snapshotVersion is the version of the encoding of Result, it is increased when the encoding changes incompatibly.
+const snapshotVersion = 1
+
+// snapshot is the exported form of Result for encoding/gob.
+type snapshot struct {
+	Version   int
+	Succeeded int64
+	Failed    int64
+	Dropped   int64
+	Late      int64
+	Latencies storeSnapshot
+	Errors    []errorGroupSnapshot
+
+	// Series is nil for the children, which do not record the time series.
+	Series    *seriesSnapshot
+	Stages    []*snapshot
+	Corrected *snapshot
+	Tags      []tagSnapshot
+	Scenarios []scenarioSnapshot
+	Timings   []timingSnapshot
+
+	WindowStart time.Time
+	WindowEnd   time.Time
+}
+
+type storeSnapshot struct {
+	// Raw is the latencies of rawStore, it is used when Precision is 0.
+	Raw []float64
+
+	// The fields of histStore.
+	Precision float64
+	Buckets   []int64
+	Offset    int
+	Zeros     int64
+	N         int64
+	Sum       float64
+	Min       float64
+	Max       float64
+}
+
+type errorGroupSnapshot struct {
+	Key       string
+	Count     int64
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Samples   []string
+
+	// Seq keeps the order of the groups of the same count.
+	Seq int
+}
+
+type seriesSnapshot struct {
+	Interval time.Duration
+	Origin   time.Time
+
+	// Buckets has the zero value for the intervals in which no request completed, because gob cannot encode nil elements.
+	// It is restored as nil, since a recorded interval has at least one request.
+	Buckets []seriesBucketSnapshot
+}
+
+type seriesBucketSnapshot struct {
+	Succeeded int64
+	Failed    int64
+	Latencies storeSnapshot
+}
+
+type tagSnapshot struct {
+	Tag    Tag
+	Result *snapshot
+}
+
+type scenarioSnapshot struct {
+	Name   string
+	Result *snapshot
+}
+
+type timingSnapshot struct {
+	Name   string
+	Result *snapshot
+}
+
+// MarshalBinary encodes everything recorded in r, so that it can be saved and reported later without running the test again.
+// The error classifier is not encoded, and the sample errors are encoded only as their messages.
+func (r *Result) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(r.snapshot()); err != nil {
+		return nil, fmt.Errorf("failed to encode result: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary restores r from the data encoded by MarshalBinary.
+// r must be empty, for example new(Result), and can still record more requests afterwards.
+// The sample errors of ErrorGroups are restored as the errors which have the same messages.
+func (r *Result) UnmarshalBinary(data []byte) error {
+	var s snapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return fmt.Errorf("failed to decode result: %w", err)
+	}
+	if s.Version != snapshotVersion {
+		return fmt.Errorf("unsupported result version: %d", s.Version)
+	}
+	r.restore(&s)
+	return nil
+}
+
+func (r *Result) snapshot() *snapshot {
+	s := &snapshot{
+		Version:   snapshotVersion,
+		Succeeded: r.Succeeded(),
+		Failed:    r.Failed(),
+		Dropped:   r.Dropped(),
+		Late:      r.Late(),
+	}
+
+	r.latenciesMu.Lock()
+	s.Latencies = snapshotStore(r.latencies)
+	r.latenciesMu.Unlock()
+
+	for _, g := range r.ErrorGroups() {
+		samples := make([]string, 0, len(g.Samples))
+		for _, err := range g.Samples {
+			samples = append(samples, err.Error())
+		}
+		s.Errors = append(s.Errors, errorGroupSnapshot{Key: g.Key, Count: g.Count, FirstSeen: g.FirstSeen, LastSeen: g.LastSeen, Samples: samples, Seq: g.seq})
+	}
+
+	r.seriesMu.Lock()
+	if r.series.newStore != nil {
+		ss := &seriesSnapshot{Interval: r.series.interval, Origin: r.series.origin}
+		for _, b := range r.series.buckets {
+			var bs seriesBucketSnapshot
+			if b != nil {
+				bs = seriesBucketSnapshot{Succeeded: b.succeeded, Failed: b.failed, Latencies: snapshotStore(b.latencies)}
+			}
+			ss.Buckets = append(ss.Buckets, bs)
+		}
+		s.Series = ss
+	}
+	r.seriesMu.Unlock()
+
+	for _, st := range r.Stages() {
+		s.Stages = append(s.Stages, st.snapshot())
+	}
+	r.correctedMu.Lock()
+	corrected := r.corrected
+	r.correctedMu.Unlock()
+	if corrected != nil {
+		s.Corrected = corrected.snapshot()
+	}
+	for _, t := range r.Tags() {
+		s.Tags = append(s.Tags, tagSnapshot{Tag: t, Result: r.Tagged(t).snapshot()})
+	}
+	for _, name := range r.Scenarios() {
+		s.Scenarios = append(s.Scenarios, scenarioSnapshot{Name: name, Result: r.Scenario(name).snapshot()})
+	}
+	for _, name := range r.Timings() {
+		s.Timings = append(s.Timings, timingSnapshot{Name: name, Result: r.Timing(name).snapshot()})
+	}
+
+	s.WindowStart, s.WindowEnd = r.Window()
+	return s
+}
+
+// restore sets the fields of the empty r from s.
+func (r *Result) restore(s *snapshot) {
+	r.succeeded = s.Succeeded
+	r.failed = s.Failed
+	r.dropped = s.Dropped
+	r.late = s.Late
+	r.latencies = s.Latencies.restore()
+
+	for _, g := range s.Errors {
+		if r.errors.groups == nil {
+			r.errors.groups = make(map[string]*ErrorGroup, len(s.Errors))
+		}
+		samples := make([]error, 0, len(g.Samples))
+		for _, msg := range g.Samples {
+			samples = append(samples, errors.New(msg))
+		}
+		r.errors.groups[g.Key] = &ErrorGroup{Key: g.Key, Count: g.Count, FirstSeen: g.FirstSeen, LastSeen: g.LastSeen, Samples: samples, seq: g.Seq}
+	}
+
+	if s.Series != nil {
+		p := s.Latencies.Precision // 0 for the raw latencies, whose series is also in the histogram of seriesPrecision.
+		r.series = newTimeSeries(func() store { return newHistStore(max(p, seriesPrecision)) })
+		r.series.interval = s.Series.Interval
+		r.series.origin = s.Series.Origin
+		for _, bs := range s.Series.Buckets {
+			var b *seriesBucket
+			if bs.Succeeded+bs.Failed != 0 {
+				b = &seriesBucket{succeeded: bs.Succeeded, failed: bs.Failed, latencies: bs.Latencies.restore()}
+			}
+			r.series.buckets = append(r.series.buckets, b)
+		}
+	}
+
+	for _, st := range s.Stages {
+		r.stages = append(r.stages, restoreChild(st))
+	}
+	if s.Corrected != nil {
+		r.corrected = restoreChild(s.Corrected)
+	}
+	for _, t := range s.Tags {
+		if r.tags == nil {
+			r.tags = make(map[Tag]*Result, len(s.Tags))
+		}
+		r.tags[t.Tag] = restoreChild(t.Result)
+	}
+	for _, sc := range s.Scenarios {
+		if r.scenarios == nil {
+			r.scenarios = make(map[string]*Result, len(s.Scenarios))
+		}
+		r.scenarios[sc.Name] = restoreChild(sc.Result)
+		r.scenarioNames = append(r.scenarioNames, sc.Name)
+	}
+	for _, t := range s.Timings {
+		if r.timings == nil {
+			r.timings = make(map[string]*Result, len(s.Timings))
+		}
+		r.timings[t.Name] = restoreChild(t.Result)
+		r.timingNames = append(r.timingNames, t.Name)
+	}
+
+	r.window.start, r.window.end = s.WindowStart, s.WindowEnd
+}
+
+func restoreChild(s *snapshot) *Result {
+	r := &Result{}
+	r.restore(s)
+	return r
+}
+
+// snapshotStore copies st, because it is encoded after the lock of st is released.
+func snapshotStore(st store) storeSnapshot {
+	switch s := st.(type) {
+	case *rawStore:
+		return storeSnapshot{Raw: slices.Clone(s.latencies)}
+	case *histStore:
+		return storeSnapshot{
+			Precision: s.precision,
+			Buckets:   slices.Clone(s.buckets),
+			Offset:    s.offset,
+			Zeros:     s.zeros,
+			N:         s.n,
+			Sum:       s.sum,
+			Min:       s.min,
+			Max:       s.max,
+		}
+	}
+	panic(fmt.Sprintf("unknown store: %T", st))
+}
+
+func (ss storeSnapshot) restore() store {
+	if ss.Precision == 0 {
+		s := newRawStore(0)
+		s.latencies = ss.Raw
+		return s
+	}
+	s := newHistStore(ss.Precision)
+	s.buckets = ss.Buckets
+	s.offset = ss.Offset
+	s.zeros = ss.Zeros
+	s.n = ss.N
+	s.sum = ss.Sum
+	s.min = ss.Min
+	s.max = ss.Max
+	return s
+}
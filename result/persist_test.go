@@ -0,0 +1,130 @@
+package result
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalBinary(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		newResult func() (*Result, error)
+	}{
+		"raw": {
+			newResult: func() (*Result, error) { return WithCapacity(0) },
+		},
+		"histogram": {
+			newResult: New,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := tc.newResult()
+			require.NoError(t, err)
+			res.AppendSuccess(0.1)
+			res.AppendSuccess(0.2)
+			res.AppendFail(0.3, errors.New("err1"))
+			res.AppendFail(0.4, errors.New("err2"))
+			res.AppendFail(0.5, errors.New("err2"))
+			res.AddDropped()
+			res.AddLate()
+			res.Stage(1).AppendSuccess(0.2)
+			res.Corrected().AppendSuccess(0.6)
+			res.Tagged(Tag{Key: "endpoint", Value: "search"}).AppendFail(0.3, errors.New("err1"))
+			res.Scenario("b").AppendSuccess(0.1)
+			res.Scenario("a").AppendSuccess(0.2)
+			res.Timing("ttfb").AppendSuccess(0.05)
+			base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			res.ExtendWindow(base, base.Add(2*time.Second))
+
+			data, err := res.MarshalBinary()
+			require.NoError(t, err)
+			got := new(Result)
+			require.NoError(t, got.UnmarshalBinary(data))
+
+			assert.Equal(t, res.Succeeded(), got.Succeeded())
+			assert.Equal(t, res.Failed(), got.Failed())
+			assert.Equal(t, res.Dropped(), got.Dropped())
+			assert.Equal(t, res.Late(), got.Late())
+			assert.Equal(t, res.Latencies(), got.Latencies())
+			for _, p := range []int{0, 50, 90, 99, 100} {
+				want, err := res.PercentileLatency(p)
+				require.NoError(t, err)
+				v, err := got.PercentileLatency(p)
+				require.NoError(t, err)
+				assert.Equal(t, want, v, "p%d", p)
+			}
+			wantMean, _ := res.MeanLatency()
+			gotMean, _ := got.MeanLatency()
+			assert.Equal(t, wantMean, gotMean)
+			assert.Equal(t, res.Error(), got.Error())
+			assert.Equal(t, stripMonotonic(res.TimeSeries()), got.TimeSeries())
+			assert.Equal(t, 2*time.Second, got.Elapsed())
+
+			require.Len(t, got.Stages(), 2)
+			assert.Equal(t, int64(0), got.Stage(0).Succeeded())
+			assert.Equal(t, int64(1), got.Stage(1).Succeeded())
+			assert.Equal(t, int64(1), got.Corrected().Succeeded())
+			assert.Equal(t, []Tag{{Key: "endpoint", Value: "search"}}, got.Tags())
+			assert.Equal(t, "err1", got.Tagged(Tag{Key: "endpoint", Value: "search"}).Error())
+			assert.Equal(t, []string{"b", "a"}, got.Scenarios())
+			assert.Nil(t, got.Scenario("a").TimeSeries())
+			assert.Equal(t, []string{"ttfb"}, got.Timings())
+			assert.Equal(t, int64(1), got.Timing("ttfb").Succeeded())
+
+			// The restored Result keeps recording.
+			got.AppendFail(0.7, errors.New("err1"))
+			assert.Equal(t, "err1 (x2), err2 (x2)", got.Error())
+			var failed int64
+			for _, p := range got.TimeSeries() {
+				failed += p.Failed
+			}
+			assert.Equal(t, int64(4), failed)
+		})
+	}
+}
+
+func TestMarshalBinarySeriesGap(t *testing.T) {
+	t.Parallel()
+
+	res, err := New()
+	require.NoError(t, err)
+	origin := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	res.series.add(origin, 0.1, false)
+	res.series.add(origin.Add(2500*time.Millisecond), 0.2, true) // No request completed in the second interval.
+
+	data, err := res.MarshalBinary()
+	require.NoError(t, err)
+	got := new(Result)
+	require.NoError(t, got.UnmarshalBinary(data))
+
+	assert.Equal(t, res.TimeSeries(), got.TimeSeries())
+	assert.Nil(t, got.series.buckets[1], "the empty interval is restored as nil")
+
+	// The empty interval keeps recording.
+	got.series.add(origin.Add(1500*time.Millisecond), 0.3, false)
+	assert.Equal(t, int64(1), got.TimeSeries()[1].Succeeded)
+}
+
+func TestUnmarshalBinaryInvalid(t *testing.T) {
+	t.Parallel()
+
+	assert.Error(t, new(Result).UnmarshalBinary([]byte("invalid")))
+}
+
+// stripMonotonic drops the monotonic clock reading from the times of points, which is not encoded.
+func stripMonotonic(points []Point) []Point {
+	for i := range points {
+		points[i].Time = points[i].Time.Round(0)
+	}
+	return points
+}
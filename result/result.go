@@ -3,8 +3,7 @@ package result
 import (
 	"bytes"
 	"errors"
-	"sort"
-	"strings"
+	"math"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -13,7 +12,7 @@ import (
 )
 
 const (
-	defaultCapacity = 100_000_000
+	defaultPrecision = 0.001
 )
 
 // Result represents to records the results (number of successes or failures and latency) of the tests performed by Otchkiss.
@@ -21,76 +20,114 @@ const (
 type Result struct {
 	succeeded int64
 	failed    int64
-	latencies []float64
-	sorted    bool
-	errors    []error
+	dropped   int64
+	late      int64
+	latencies store
+	errors    errorGroups
+	stages    []*Result
+	corrected *Result
+	series    timeSeries
+	tags      map[Tag]*Result
+	window    window
+
+	scenarios     map[string]*Result
+	scenarioNames []string
+
+	timings     map[string]*Result
+	timingNames []string
 
 	latenciesMu sync.Mutex
 	errorsMu    sync.Mutex
+	stagesMu    sync.Mutex
+	correctedMu sync.Mutex
+	seriesMu    sync.Mutex
+	tagsMu      sync.Mutex
+	scenariosMu sync.Mutex
+	timingsMu   sync.Mutex
+	windowMu    sync.Mutex
 }
 
-// New returns Result instance by default capacity (100M).
+// New returns Result instance which records latencies in the histogram of default precision (0.1%).
+// Its memory does not grow with the number of requests. Use WithCapacity if exact latencies are needed.
 func New() (*Result, error) {
-	return new(defaultCapacity)
+	return WithHistogram(defaultPrecision)
 }
 
-// WithCapacity returns Result instance by given capacity.
+// WithCapacity returns Result instance which keeps every latency as is, and preallocates them by given capacity.
+// The percentiles of each interval of TimeSeries are still taken from the histogram, so that the latencies are not kept twice.
 // When case of capacity shortage performance may be affected due to automatic memory allocation.
 // However too large value may cause OOM.
 func WithCapacity(cap int) (*Result, error) {
-	return new(cap)
-}
-
-func new(cap int) (*Result, error) {
 	if cap < 0 {
 		return nil, errors.New("capacity must be >= 0")
 	}
 
 	return &Result{
-		latencies: make([]float64, 0, cap),
-		errors:    make([]error, 0, cap),
+		latencies: newRawStore(cap),
+		series:    newTimeSeries(func() store { return newHistStore(seriesPrecision) }),
 	}, nil
 }
 
-func (r *Result) Error() string {
-	r.errorsMu.Lock()
-	defer r.errorsMu.Unlock()
-
-	const delimiter = ", "
-	var errStr string
-	for _, err := range r.errors {
-		errStr += err.Error() + delimiter
+// WithHistogram returns Result instance which records latencies in the logarithmic histogram.
+// precision is the max relative error of the percentiles, for example 0.01 means 1%.
+// Smaller precision needs more memory, but it is bounded regardless of the number of requests (about 256KB at 0.1%).
+func WithHistogram(precision float64) (*Result, error) {
+	if !(precision > 0 && precision < 1) {
+		return nil, errors.New("precision must be between 0 and 1")
 	}
 
-	return strings.TrimSuffix(errStr, delimiter)
+	return &Result{
+		latencies: newHistStore(precision),
+		series:    newTimeSeries(func() store { return newHistStore(max(precision, seriesPrecision)) }),
+	}, nil
 }
 
-func (r *Result) Errors() []error {
+// child returns an empty Result which records latencies in the same way as r, but not the time series.
+func (r *Result) child() *Result {
+	r.latenciesMu.Lock()
+	defer r.latenciesMu.Unlock()
 	r.errorsMu.Lock()
 	defer r.errorsMu.Unlock()
-	return r.errors
+
+	return &Result{
+		latencies: r.latencies.empty(),
+		errors:    errorGroups{classifier: r.errors.classifier},
+	}
+}
+
+// Empty returns an empty Result which records in the same way as r,
+// that is the latencies in the same store, the time series of the same interval and the errors by the same classifier.
+func (r *Result) Empty() *Result {
+	e := r.child()
+
+	r.seriesMu.Lock()
+	defer r.seriesMu.Unlock()
+	if r.series.newStore != nil {
+		e.series = newTimeSeries(r.series.newStore)
+		e.series.interval = r.series.interval
+	}
+	return e
 }
 
 func (r *Result) AppendSuccess(t float64) {
 	atomic.AddInt64(&r.succeeded, 1)
 	r.appendLatency(t)
+	r.appendSeries(t, false)
 }
 
 func (r *Result) AppendFail(t float64, err error) {
 	atomic.AddInt64(&r.failed, 1)
 	r.appendLatency(t)
+	r.appendSeries(t, true)
 
-	r.errorsMu.Lock()
-	defer r.errorsMu.Unlock()
-	r.errors = append(r.errors, err)
+	r.appendError(err)
 }
 
 func (r *Result) appendLatency(t float64) {
 	r.latenciesMu.Lock()
 	defer r.latenciesMu.Unlock()
 
-	r.latencies = append(r.latencies, t)
-	r.sorted = false
+	r.latencies.add(t)
 }
 
 func (r *Result) Succeeded() int64 {
@@ -101,17 +138,39 @@ func (r *Result) Failed() int64 {
 	return atomic.LoadInt64(&r.failed)
 }
 
+// AddDropped counts a request which was not sent because the backlog was full.
+func (r *Result) AddDropped() {
+	atomic.AddInt64(&r.dropped, 1)
+}
+
+// Dropped returns the number of the requests which were not sent because the backlog was full.
+func (r *Result) Dropped() int64 {
+	return atomic.LoadInt64(&r.dropped)
+}
+
+// AddLate counts a request which started later than scheduled because no worker was available.
+func (r *Result) AddLate() {
+	atomic.AddInt64(&r.late, 1)
+}
+
+// Late returns the number of the requests which started later than scheduled because no worker was available.
+func (r *Result) Late() int64 {
+	return atomic.LoadInt64(&r.late)
+}
+
+// Latencies returns every recorded latency.
+// It returns nil when the Result records latencies in the histogram.
 func (r *Result) Latencies() []float64 {
 	r.latenciesMu.Lock()
 	defer r.latenciesMu.Unlock()
-	return r.latencies
+	return r.latencies.values()
 }
 
 func (r *Result) PercentileLatency(p int) (float64, error) {
 	r.latenciesMu.Lock()
 	defer r.latenciesMu.Unlock()
 
-	if len(r.latencies) == 0 {
+	if r.latencies.count() == 0 {
 		return 0, errors.New("no result data")
 	}
 
@@ -119,22 +178,18 @@ func (r *Result) PercentileLatency(p int) (float64, error) {
 		return 0, errors.New("p must be between 0 and 100")
 	}
 
-	if !r.sorted {
-		sort.SliceStable(r.latencies, func(i, j int) bool {
-			return r.latencies[i] < r.latencies[j]
-		})
-		r.sorted = true
-	}
+	return r.latencies.percentile(p), nil
+}
+
+// MeanLatency returns the average of the latencies.
+func (r *Result) MeanLatency() (float64, error) {
+	r.latenciesMu.Lock()
+	defer r.latenciesMu.Unlock()
 
-	switch {
-	case p == 0:
-		return r.latencies[0], nil
-	case p == 100:
-		return r.latencies[len(r.latencies)-1], nil
-	default:
-		idx := float64(len(r.latencies)) * (float64(p) / 100)
-		return r.latencies[int(idx-1)], nil
+	if r.latencies.count() == 0 {
+		return 0, errors.New("no result data")
 	}
+	return r.latencies.mean(), nil
 }
 
 func (r *Result) Histogram(bins, width int) (string, error) {
@@ -142,7 +197,7 @@ func (r *Result) Histogram(bins, width int) (string, error) {
 	defer r.latenciesMu.Unlock()
 
 	var buf bytes.Buffer
-	hi := histogram.Hist(bins, r.latencies)
+	hi := r.latencies.histogram(bins)
 	fn := func(v float64) string {
 		return time.Duration(v * float64(time.Second)).String()
 	}
@@ -153,3 +208,94 @@ func (r *Result) Histogram(bins, width int) (string, error) {
 
 	return buf.String(), nil
 }
+
+// Bucket is a range of the latencies in seconds and the number of the requests in it.
+type Bucket struct {
+	// Min is the inclusive lower bound, and Max is the exclusive upper bound except for the last bucket.
+	Min   float64
+	Max   float64
+	Count int64
+}
+
+// HistogramBuckets returns the latencies divided into bins buckets of the same width, which Histogram renders.
+func (r *Result) HistogramBuckets(bins int) []Bucket {
+	r.latenciesMu.Lock()
+	defer r.latenciesMu.Unlock()
+
+	hi := r.latencies.histogram(bins)
+	buckets := make([]Bucket, 0, len(hi.Buckets))
+	for _, b := range hi.Buckets {
+		buckets = append(buckets, Bucket{Min: b.Min, Max: b.Max, Count: int64(b.Count)})
+	}
+	return buckets
+}
+
+// LatencyCount is a latency in seconds and the number of the requests which took it.
+type LatencyCount struct {
+	Value float64
+	Count int64
+}
+
+// LatencyCounts returns the distribution of the latencies in ascending order.
+// When the Result records latencies in the histogram, the values are the representatives of its buckets, which are within the precision.
+func (r *Result) LatencyCounts() []LatencyCount {
+	r.latenciesMu.Lock()
+	defer r.latenciesMu.Unlock()
+
+	var counts []LatencyCount
+	if r.latencies.count() == 0 {
+		return counts
+	}
+	lo, hi := r.latencies.percentile(0), r.latencies.percentile(100)
+	r.latencies.each(func(v float64, c int64) bool {
+		v = math.Min(math.Max(v, lo), hi)
+		if n := len(counts); n != 0 && counts[n-1].Value == v {
+			counts[n-1].Count += c // The buckets clamped to the min or max are merged.
+		} else {
+			counts = append(counts, LatencyCount{Value: v, Count: c})
+		}
+		return true
+	})
+	return counts
+}
+
+// Stage returns the Result which records the samples of the i-th stage.
+// It is created on the first call, so the same instance is returned for the same i.
+func (r *Result) Stage(i int) *Result {
+	r.stagesMu.Lock()
+	defer r.stagesMu.Unlock()
+
+	for len(r.stages) <= i {
+		r.stages = append(r.stages, r.child())
+	}
+	return r.stages[i]
+}
+
+// Stages returns the Results of each stage recorded so far.
+// The index corresponds to the stage, and stages which never recorded anything are empty.
+func (r *Result) Stages() []*Result {
+	r.stagesMu.Lock()
+	defer r.stagesMu.Unlock()
+	return append([]*Result(nil), r.stages...)
+}
+
+// Corrected returns the Result which records the latencies measured from the intended start times (coordinated omission corrected).
+// It is recorded only when the test runs in the open model.
+func (r *Result) Corrected() *Result {
+	r.correctedMu.Lock()
+	defer r.correctedMu.Unlock()
+
+	if r.corrected == nil {
+		r.corrected = r.child()
+	}
+	return r.corrected
+}
+
+// HasCorrected reports whether any latency measured from the intended start time has been recorded.
+// Unlike Corrected, it does not create the Result.
+func (r *Result) HasCorrected() bool {
+	r.correctedMu.Lock()
+	c := r.corrected
+	r.correctedMu.Unlock()
+	return c != nil && c.Succeeded()+c.Failed() != 0
+}
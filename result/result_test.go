@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -86,7 +87,7 @@ func TestPercentileLatency(t *testing.T) {
 
 			res, err := WithCapacity(len(tc.latencies))
 			require.NoError(t, err)
-			res.latencies = tc.latencies
+			res.latencies = &rawStore{latencies: tc.latencies}
 
 			actualPercentile, err := res.PercentileLatency(tc.percentile)
 			tc.wantError(t, err)
@@ -119,7 +120,9 @@ func TestErrorErrors(t *testing.T) {
 
 			res, err := WithCapacity(len(tc.errs))
 			require.NoError(t, err)
-			res.errors = tc.errs
+			for _, err := range tc.errs {
+				res.AppendFail(0, err)
+			}
 
 			assert.Equal(t, tc.wantErrorString, res.Error())
 			diff := cmp.Diff(tc.errs, res.Errors(), cmpopts.EquateErrors())
@@ -144,6 +147,7 @@ func TestRace(t *testing.T) {
 			res.AppendFail(1, fmt.Errorf("err"))
 			_ = res.Error()
 			_ = res.Errors()
+			_ = res.ErrorGroups()
 			_ = res.Succeeded()
 			_ = res.Failed()
 			_ = res.Latencies()
@@ -156,7 +160,10 @@ func TestRace(t *testing.T) {
 	assert.Equal(t, int64(round), res.Succeeded())
 	assert.Equal(t, int64(round), res.Failed())
 	assert.Len(t, res.Latencies(), round*2)
-	assert.Len(t, res.Errors(), round)
+	groups := res.ErrorGroups()
+	require.Len(t, groups, 1)
+	assert.Equal(t, int64(round), groups[0].Count)
+	assert.Len(t, res.Errors(), errorSamples)
 }
 
 func TestHistogram(t *testing.T) {
@@ -206,10 +213,108 @@ func TestHistogram(t *testing.T) {
 		t.Run(tn, func(t *testing.T) {
 			t.Parallel()
 
-			r := Result{latencies: tc.latencies}
+			r := Result{latencies: &rawStore{latencies: tc.latencies}}
 			actual, err := r.Histogram(tc.bins, tc.width)
 			assert.NoError(t, err)
 			assert.Equal(t, tc.want, actual)
 		})
 	}
 }
+
+func TestHistogramBuckets(t *testing.T) {
+	t.Parallel()
+
+	r := Result{latencies: &rawStore{latencies: []float64{0, 1, 1, 3}}}
+	assert.Equal(t, []Bucket{
+		{Min: 0, Max: 1, Count: 1},
+		{Min: 1, Max: 2, Count: 2},
+		{Min: 2, Max: 3, Count: 1},
+	}, r.HistogramBuckets(3))
+
+	empty := Result{latencies: &rawStore{}}
+	assert.Empty(t, empty.HistogramBuckets(3))
+}
+
+func TestLatencyCounts(t *testing.T) {
+	t.Parallel()
+
+	r := Result{latencies: &rawStore{latencies: []float64{3, 1, 0, 1}}}
+	assert.Equal(t, []LatencyCount{{Value: 0, Count: 1}, {Value: 1, Count: 2}, {Value: 3, Count: 1}}, r.LatencyCounts())
+
+	h, err := WithHistogram(0.01)
+	require.NoError(t, err)
+	for _, v := range []float64{0.1, 0.1, 0.2} {
+		h.AppendSuccess(v)
+	}
+	counts := h.LatencyCounts()
+	require.Len(t, counts, 2)
+	assert.Equal(t, LatencyCount{Value: 0.1, Count: 2}, counts[0], "clamped to the min")
+	assert.InEpsilon(t, 0.2, counts[1].Value, 0.01)
+	assert.Equal(t, int64(1), counts[1].Count)
+
+	empty := Result{latencies: &rawStore{}}
+	assert.Empty(t, empty.LatencyCounts())
+}
+
+func TestStage(t *testing.T) {
+	t.Parallel()
+
+	res, err := WithCapacity(0)
+	require.NoError(t, err)
+	assert.Empty(t, res.Stages())
+
+	res.Stage(2).AppendSuccess(1)
+	res.Stage(2).AppendFail(2, fmt.Errorf("err"))
+	res.Stage(0).AppendSuccess(3)
+
+	stages := res.Stages()
+	require.Len(t, stages, 3)
+	assert.Equal(t, int64(1), stages[0].Succeeded())
+	assert.Equal(t, int64(0), stages[1].Succeeded()+stages[1].Failed())
+	assert.Equal(t, int64(1), stages[2].Succeeded())
+	assert.Equal(t, int64(1), stages[2].Failed())
+	assert.Same(t, stages[2], res.Stage(2))
+	assert.Zero(t, res.Succeeded(), "stage results must not affect the parent")
+}
+
+func TestCorrected(t *testing.T) {
+	t.Parallel()
+
+	res, err := WithCapacity(0)
+	require.NoError(t, err)
+	res.AppendSuccess(1)
+	assert.False(t, res.HasCorrected())
+	assert.Nil(t, res.corrected, "HasCorrected must not create the Result")
+	res.Corrected().AppendSuccess(3)
+	assert.True(t, res.HasCorrected())
+
+	assert.Same(t, res.Corrected(), res.Corrected())
+	assert.Equal(t, []float64{1}, res.Latencies())
+	assert.Equal(t, []float64{3}, res.Corrected().Latencies())
+}
+
+func TestEmpty(t *testing.T) {
+	t.Parallel()
+
+	res, err := WithHistogram(0.01)
+	require.NoError(t, err)
+	require.NoError(t, res.SetTimeSeriesInterval(100*time.Millisecond))
+	res.SetErrorClassifier(func(error) string { return "classified" })
+	res.AppendSuccess(1)
+
+	e := res.Empty()
+	assert.Equal(t, int64(0), e.Succeeded())
+	assert.Nil(t, e.TimeSeries())
+	e.AppendFail(2, fmt.Errorf("err"))
+	assert.Equal(t, "classified", e.Error())
+	assert.Equal(t, 0.01, e.latencies.(*histStore).precision)
+	assert.Equal(t, 100*time.Millisecond, e.TimeSeries()[0].Interval)
+	assert.Equal(t, int64(1), res.Succeeded()+res.Failed(), "r is not changed")
+
+	raw, err := WithCapacity(0)
+	require.NoError(t, err)
+	assert.IsType(t, &rawStore{}, raw.Empty().latencies)
+	child := raw.Stage(0).Empty()
+	child.AppendSuccess(1)
+	assert.Nil(t, child.TimeSeries(), "a child does not record the time series")
+}
@@ -0,0 +1,64 @@
+package result
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// rollingIntervals is how many intervals of the time series a Rolling window is divided into at least.
+const rollingIntervals = 10
+
+// Rolling records only the requests completed within the latest duration, for example to watch the error rate during the test.
+// The requests are counted in the intervals of the time series, so its memory does not grow with the number of requests nor the length of the test.
+type Rolling struct {
+	d time.Duration
+
+	mu     sync.Mutex
+	series timeSeries
+}
+
+// NewRolling returns Rolling which keeps the requests of the latest d.
+// The intervals are 1s, or d/10 when d is shorter than 10s, so the requests up to an interval older than d are also kept.
+func NewRolling(d time.Duration) (*Rolling, error) {
+	if !(d > 0) {
+		return nil, errors.New("duration must be > 0 sec")
+	}
+
+	ts := newTimeSeries(func() store { return newHistStore(seriesPrecision) })
+	ts.interval = min(defaultInterval, d/rollingIntervals)
+	if ts.interval == 0 {
+		ts.interval = d
+	}
+	return &Rolling{
+		d:      d,
+		series: ts,
+	}, nil
+}
+
+// Append records a request completed at the time at, whose latency is t seconds.
+func (w *Rolling) Append(at time.Time, t float64, failed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.series.add(at, t, failed)
+}
+
+// Result drops the intervals which ended before now minus the duration, and returns the Result of the rest.
+// since is the beginning of the first remaining interval, it is zero when nothing has been recorded.
+// The latencies are in the histogram of 1% precision, and neither the errors nor the time series are recorded.
+func (w *Rolling) Result(now time.Time) (r *Result, since time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.series.trim(now.Add(-w.d))
+	r = &Result{latencies: newHistStore(seriesPrecision)}
+	for _, b := range w.series.buckets {
+		if b == nil {
+			continue
+		}
+		r.succeeded += b.succeeded
+		r.failed += b.failed
+		r.latencies.merge(b.latencies)
+	}
+	return r, w.series.origin
+}
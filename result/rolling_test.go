@@ -0,0 +1,45 @@
+package result
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRolling(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewRolling(0)
+	assert.Error(t, err)
+
+	w, err := NewRolling(1 * time.Second) // The intervals are 100ms.
+	require.NoError(t, err)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w.Append(base, 0.1, false)
+	w.Append(base.Add(150*time.Millisecond), 0.2, true)
+	w.Append(base.Add(950*time.Millisecond), 0.3, false)
+
+	r, since := w.Result(base.Add(1050 * time.Millisecond))
+	assert.Equal(t, base, since, "the first interval ends after 50ms")
+	assert.Equal(t, int64(2), r.Succeeded())
+	assert.Equal(t, int64(1), r.Failed())
+	p100, err := r.PercentileLatency(100)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.3, p100, 0.3*seriesPrecision)
+
+	r, since = w.Result(base.Add(1250 * time.Millisecond))
+	assert.Equal(t, base.Add(200*time.Millisecond), since)
+	assert.Equal(t, int64(1), r.Succeeded())
+	assert.Equal(t, int64(0), r.Failed())
+
+	r, _ = w.Result(base.Add(10 * time.Second))
+	assert.Equal(t, int64(0), r.Succeeded()+r.Failed())
+	assert.Empty(t, w.series.buckets, "the old intervals are released")
+
+	// It keeps recording after everything was dropped.
+	w.Append(base.Add(10*time.Second), 0.4, false)
+	r, _ = w.Result(base.Add(10*time.Second + 500*time.Millisecond))
+	assert.Equal(t, int64(1), r.Succeeded())
+}
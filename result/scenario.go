@@ -0,0 +1,26 @@
+package result
+
+// Scenario returns the Result which records the samples of the named scenario.
+// It is created on the first call, so the same instance is returned for the same name.
+func (r *Result) Scenario(name string) *Result {
+	r.scenariosMu.Lock()
+	defer r.scenariosMu.Unlock()
+
+	if r.scenarios == nil {
+		r.scenarios = make(map[string]*Result)
+	}
+	s, ok := r.scenarios[name]
+	if !ok {
+		s = r.child()
+		r.scenarios[name] = s
+		r.scenarioNames = append(r.scenarioNames, name)
+	}
+	return s
+}
+
+// Scenarios returns the names of the scenarios recorded so far in the order of the first record.
+func (r *Result) Scenarios() []string {
+	r.scenariosMu.Lock()
+	defer r.scenariosMu.Unlock()
+	return append([]string(nil), r.scenarioNames...)
+}
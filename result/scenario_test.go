@@ -0,0 +1,26 @@
+package result
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScenario(t *testing.T) {
+	t.Parallel()
+
+	res, err := New()
+	require.NoError(t, err)
+	assert.Empty(t, res.Scenarios())
+
+	res.Scenario("search").AppendSuccess(1)
+	res.Scenario("browse").AppendSuccess(2)
+	res.Scenario("search").AppendSuccess(3)
+
+	assert.Equal(t, []string{"search", "browse"}, res.Scenarios())
+	assert.Same(t, res.Scenario("search"), res.Scenario("search"))
+	assert.Equal(t, int64(2), res.Scenario("search").Succeeded())
+	assert.Equal(t, int64(1), res.Scenario("browse").Succeeded())
+	assert.Zero(t, res.Succeeded(), "scenario results must not affect the parent")
+}
@@ -0,0 +1,319 @@
+package result
+
+import (
+	"math"
+	"sort"
+
+	"github.com/aybabtme/uniplot/histogram"
+)
+
+// store records latencies in seconds.
+// It is not thread safe, so Result guards it.
+type store interface {
+	add(v float64)
+	count() int64
+	// percentile returns the p-th percentile, the store must not be empty.
+	percentile(p int) float64
+	mean() float64
+	histogram(bins int) histogram.Histogram
+	// each calls fn with the distinct values and their counts in ascending order until fn returns false.
+	// The values of histStore are the representative values of the buckets.
+	each(fn func(v float64, c int64) bool)
+	// values returns the recorded values as is, or nil when the store does not keep them.
+	values() []float64
+	// empty returns a new empty store of the same kind.
+	empty() store
+	// merge adds the latencies of other, which must not be shared with the store.
+	merge(other store)
+}
+
+// rawStore keeps every latency, so it is exact but its memory grows with the number of requests.
+type rawStore struct {
+	latencies []float64
+	sorted    bool
+}
+
+func newRawStore(cap int) *rawStore {
+	return &rawStore{
+		latencies: make([]float64, 0, cap),
+	}
+}
+
+func (s *rawStore) add(v float64) {
+	s.latencies = append(s.latencies, v)
+	s.sorted = false
+}
+
+func (s *rawStore) count() int64 {
+	return int64(len(s.latencies))
+}
+
+func (s *rawStore) sort() {
+	if !s.sorted {
+		sort.SliceStable(s.latencies, func(i, j int) bool {
+			return s.latencies[i] < s.latencies[j]
+		})
+		s.sorted = true
+	}
+}
+
+func (s *rawStore) percentile(p int) float64 {
+	s.sort()
+
+	switch {
+	case p == 0:
+		return s.latencies[0]
+	case p == 100:
+		return s.latencies[len(s.latencies)-1]
+	default:
+		return s.latencies[rank(int64(len(s.latencies)), p)]
+	}
+}
+
+func (s *rawStore) mean() float64 {
+	var sum float64
+	for _, l := range s.latencies {
+		sum += l
+	}
+	return sum / float64(len(s.latencies))
+}
+
+func (s *rawStore) each(fn func(v float64, c int64) bool) {
+	s.sort()
+	for i := 0; i < len(s.latencies); {
+		j := i + 1
+		for j < len(s.latencies) && s.latencies[j] == s.latencies[i] {
+			j++
+		}
+		if !fn(s.latencies[i], int64(j-i)) {
+			return
+		}
+		i = j
+	}
+}
+
+func (s *rawStore) histogram(bins int) histogram.Histogram {
+	return histogram.Hist(bins, s.latencies)
+}
+
+func (s *rawStore) values() []float64 {
+	return s.latencies
+}
+
+func (s *rawStore) empty() store {
+	return newRawStore(0)
+}
+
+// merge adds the values of other, or the representative values if other is the histogram.
+func (s *rawStore) merge(other store) {
+	other.each(func(v float64, c int64) bool {
+		for ; c > 0; c-- {
+			s.latencies = append(s.latencies, v)
+		}
+		return true
+	})
+	s.sorted = false
+}
+
+const (
+	// histMin and histMax define the range of the latency in seconds which histStore distinguishes.
+	// Values out of the range are counted in the nearest bucket, but min and max are still kept exactly.
+	histMin = 1e-9
+	histMax = 1e6
+)
+
+// histStore counts latencies in logarithmic buckets, so its memory is bounded by the precision regardless of the number of requests.
+// Each bucket covers [gamma^(k-1), gamma^k), and its representative value is within the precision of any value in it.
+type histStore struct {
+	precision float64
+	gamma     float64
+	logGamma  float64
+
+	// buckets[i] is the count of the bucket whose key is offset+i.
+	buckets []int64
+	offset  int
+	zeros   int64
+
+	n   int64
+	sum float64
+	min float64
+	max float64
+}
+
+func newHistStore(precision float64) *histStore {
+	gamma := (1 + precision) / (1 - precision)
+	return &histStore{
+		precision: precision,
+		gamma:     gamma,
+		logGamma:  math.Log(gamma),
+	}
+}
+
+func (s *histStore) key(v float64) int {
+	v = math.Min(math.Max(v, histMin), histMax)
+	return int(math.Ceil(math.Log(v) / s.logGamma))
+}
+
+// value returns the representative value of the bucket of key.
+func (s *histStore) value(key int) float64 {
+	return 2 * math.Pow(s.gamma, float64(key)) / (s.gamma + 1)
+}
+
+func (s *histStore) add(v float64) {
+	if s.n == 0 || v < s.min {
+		s.min = v
+	}
+	if s.n == 0 || v > s.max {
+		s.max = v
+	}
+	s.n++
+	s.sum += v
+
+	if v <= 0 {
+		s.zeros++
+		return
+	}
+	s.addKey(s.key(v), 1)
+}
+
+func (s *histStore) addKey(k int, c int64) {
+	switch {
+	case len(s.buckets) == 0:
+		s.buckets = make([]int64, 1)
+		s.offset = k
+	case k < s.offset:
+		grown := make([]int64, len(s.buckets)+s.offset-k)
+		copy(grown[s.offset-k:], s.buckets)
+		s.buckets = grown
+		s.offset = k
+	case k >= s.offset+len(s.buckets):
+		s.buckets = append(s.buckets, make([]int64, k-s.offset-len(s.buckets)+1)...)
+	}
+	s.buckets[k-s.offset] += c
+}
+
+func (s *histStore) count() int64 {
+	return s.n
+}
+
+func (s *histStore) percentile(p int) float64 {
+	switch {
+	case p == 0:
+		return s.min
+	case p == 100:
+		return s.max
+	}
+
+	r := int64(rank(s.n, p))
+	var v float64
+	s.each(func(bv float64, c int64) bool {
+		if r < c {
+			v = bv
+			return false
+		}
+		r -= c
+		return true
+	})
+	return math.Min(math.Max(v, s.min), s.max)
+}
+
+// each calls fn with the representative value and the count of each non empty bucket in ascending order until fn returns false.
+func (s *histStore) each(fn func(v float64, c int64) bool) {
+	if s.zeros != 0 && !fn(0, s.zeros) {
+		return
+	}
+	for i, c := range s.buckets {
+		if c == 0 {
+			continue
+		}
+		if !fn(s.value(s.offset+i), c) {
+			return
+		}
+	}
+}
+
+func (s *histStore) mean() float64 {
+	return s.sum / float64(s.n)
+}
+
+func (s *histStore) histogram(bins int) histogram.Histogram {
+	if s.n == 0 || bins == 0 {
+		return histogram.Histogram{}
+	}
+	if s.min == s.max {
+		return histogram.Histogram{
+			Min:     int(s.n),
+			Max:     int(s.n),
+			Count:   int(s.n),
+			Buckets: []histogram.Bucket{{Count: int(s.n), Min: s.min, Max: s.max}},
+		}
+	}
+
+	// Same partitioning as histogram.Hist, but each log bucket is put into the bin of its representative value.
+	scale := (s.max - s.min) / float64(bins)
+	buckets := make([]histogram.Bucket, bins)
+	for i := range buckets {
+		buckets[i] = histogram.Bucket{Min: float64(i)*scale + s.min, Max: float64(i+1)*scale + s.min}
+	}
+	var maxC int
+	s.each(func(v float64, c int64) bool {
+		v = math.Min(math.Max(v, s.min), s.max)
+		bi := min(int((v-s.min)/scale), len(buckets)-1)
+		buckets[bi].Count += int(c)
+		maxC = max(maxC, buckets[bi].Count)
+		return true
+	})
+
+	return histogram.Histogram{
+		Min:     0,
+		Max:     maxC,
+		Count:   int(s.n),
+		Buckets: buckets,
+	}
+}
+
+func (s *histStore) values() []float64 {
+	return nil
+}
+
+func (s *histStore) empty() store {
+	return newHistStore(s.precision)
+}
+
+// merge adds the buckets of other as they are if it has the same precision, otherwise adds its values one by one.
+func (s *histStore) merge(other store) {
+	o, ok := other.(*histStore)
+	if !ok || o.precision != s.precision {
+		other.each(func(v float64, c int64) bool {
+			for ; c > 0; c-- {
+				s.add(v)
+			}
+			return true
+		})
+		return
+	}
+	if o.n == 0 {
+		return
+	}
+
+	if s.n == 0 || o.min < s.min {
+		s.min = o.min
+	}
+	if s.n == 0 || o.max > s.max {
+		s.max = o.max
+	}
+	s.n += o.n
+	s.sum += o.sum
+	s.zeros += o.zeros
+	for i, c := range o.buckets {
+		if c != 0 {
+			s.addKey(o.offset+i, c)
+		}
+	}
+}
+
+// rank returns the 0-based index of the p-th percentile in n sorted values.
+func rank(n int64, p int) int {
+	idx := float64(n) * (float64(p) / 100)
+	return int(idx - 1)
+}
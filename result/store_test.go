@@ -0,0 +1,143 @@
+package result
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithHistogram(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		precision float64
+		wantError assert.ErrorAssertionFunc
+	}{
+		"ok": {
+			precision: 0.01,
+			wantError: assert.NoError,
+		},
+		"ng: zero": {
+			precision: 0,
+			wantError: assert.Error,
+		},
+		"ng: one": {
+			precision: 1,
+			wantError: assert.Error,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := WithHistogram(tc.precision)
+			tc.wantError(t, err)
+		})
+	}
+}
+
+func TestHistStorePercentile(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		precision float64
+		gen       func(rnd *rand.Rand) float64
+	}{
+		"exponential 1%": {
+			precision: 0.01,
+			gen:       func(rnd *rand.Rand) float64 { return rnd.ExpFloat64() * 0.1 },
+		},
+		"log normal 0.1%": {
+			precision: 0.001,
+			gen:       func(rnd *rand.Rand) float64 { return math.Exp(rnd.NormFloat64()) / 100 },
+		},
+		"with zeros": {
+			precision: 0.01,
+			gen: func(rnd *rand.Rand) float64 {
+				if rnd.Intn(10) == 0 {
+					return 0
+				}
+				return rnd.Float64()
+			},
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			rnd := rand.New(rand.NewSource(1))
+			raw := newRawStore(0)
+			hist := newHistStore(tc.precision)
+			for i := 0; i < 10000; i++ {
+				v := tc.gen(rnd)
+				raw.add(v)
+				hist.add(v)
+			}
+
+			require.Equal(t, raw.count(), hist.count())
+			assert.InDelta(t, raw.mean(), hist.mean(), 1e-9)
+			for _, p := range []int{0, 1, 10, 50, 90, 99, 100} {
+				want := raw.percentile(p)
+				assert.InDelta(t, want, hist.percentile(p), want*tc.precision+1e-12, "p%d", p)
+			}
+			assert.Nil(t, hist.values())
+		})
+	}
+}
+
+func TestHistStoreBoundedMemory(t *testing.T) {
+	t.Parallel()
+
+	s := newHistStore(defaultPrecision)
+	for _, v := range []float64{0, 1e-12, 1e-9, 1e-3, 1, 60, 3600, 1e9} {
+		s.add(v)
+	}
+	maxBuckets := int(math.Ceil(math.Log(histMax/histMin)/s.logGamma)) + 1
+	assert.LessOrEqual(t, len(s.buckets), maxBuckets)
+	assert.Equal(t, float64(0), s.percentile(0))
+	assert.Equal(t, 1e9, s.percentile(100))
+}
+
+func TestHistStoreHistogram(t *testing.T) {
+	t.Parallel()
+
+	latencies := []float64{
+		0,
+		0.1, 0.1,
+		0.2, 0.2, 0.2,
+		0.3, 0.3, 0.3, 0.3,
+		0.4, 0.4, 0.4, 0.4, 0.4,
+		0.5, 0.5, 0.5, 0.5,
+		0.6, 0.6, 0.6,
+		0.7, 0.7,
+		0.8,
+	}
+	raw, err := WithCapacity(len(latencies))
+	require.NoError(t, err)
+	hist, err := New()
+	require.NoError(t, err)
+	for _, l := range latencies {
+		raw.AppendSuccess(l)
+		hist.AppendSuccess(l)
+	}
+
+	want, err := raw.Histogram(9, 25)
+	require.NoError(t, err)
+	actual, err := hist.Histogram(9, 25)
+	require.NoError(t, err)
+	assert.Equal(t, want, actual)
+
+	single, err := New()
+	require.NoError(t, err)
+	single.AppendSuccess(0.5)
+	actual, err = single.Histogram(9, 25)
+	require.NoError(t, err)
+	assert.Equal(t, "500ms-500ms  100%  █▏  1\n", actual)
+}
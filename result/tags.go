@@ -0,0 +1,50 @@
+package result
+
+import (
+	"sort"
+)
+
+// Tag represents a label attached to the outcome of RequestOne, such as endpoint=search.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+func (t Tag) String() string {
+	return t.Key + "=" + t.Value
+}
+
+// Tagged returns the Result which records the samples attached tag.
+// It is created on the first call, so the same instance is returned for the same tag.
+func (r *Result) Tagged(tag Tag) *Result {
+	r.tagsMu.Lock()
+	defer r.tagsMu.Unlock()
+
+	if r.tags == nil {
+		r.tags = make(map[Tag]*Result)
+	}
+	t, ok := r.tags[tag]
+	if !ok {
+		t = r.child()
+		r.tags[tag] = t
+	}
+	return t
+}
+
+// Tags returns the tags recorded so far in ascending order of the key and the value.
+func (r *Result) Tags() []Tag {
+	r.tagsMu.Lock()
+	defer r.tagsMu.Unlock()
+
+	tags := make([]Tag, 0, len(r.tags))
+	for t := range r.tags {
+		tags = append(tags, t)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Key != tags[j].Key {
+			return tags[i].Key < tags[j].Key
+		}
+		return tags[i].Value < tags[j].Value
+	})
+	return tags
+}
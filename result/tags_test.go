@@ -0,0 +1,32 @@
+package result
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagged(t *testing.T) {
+	t.Parallel()
+
+	res, err := New()
+	require.NoError(t, err)
+	assert.Empty(t, res.Tags())
+
+	search := Tag{Key: "endpoint", Value: "search"}
+	browse := Tag{Key: "endpoint", Value: "browse"}
+	get := Tag{Key: "method", Value: "GET"}
+	res.Tagged(search).AppendSuccess(1)
+	res.Tagged(search).AppendFail(2, fmt.Errorf("err"))
+	res.Tagged(get).AppendSuccess(3)
+	res.Tagged(browse).AppendSuccess(4)
+
+	assert.Equal(t, []Tag{browse, search, get}, res.Tags())
+	assert.Same(t, res.Tagged(search), res.Tagged(search))
+	assert.Equal(t, int64(1), res.Tagged(search).Succeeded())
+	assert.Equal(t, int64(1), res.Tagged(search).Failed())
+	assert.Equal(t, "endpoint=search", search.String())
+	assert.Zero(t, res.Succeeded(), "tagged results must not affect the parent")
+}
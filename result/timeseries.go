@@ -0,0 +1,164 @@
+package result
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	defaultInterval = 1 * time.Second
+
+	// seriesPrecision is the minimum precision of the latency percentiles of each interval, and the precision of them when every latency is kept.
+	// It is coarser than the whole result by default to keep the memory small for long tests.
+	seriesPrecision = 0.01
+)
+
+// Point represents the statistics of the requests which completed in an interval.
+type Point struct {
+	// Time is the beginning of the interval.
+	Time time.Time
+
+	// Offset is the elapsed time from the beginning of the time series to Time.
+	Offset time.Duration
+
+	// Interval is the length of the interval.
+	Interval time.Duration
+
+	Succeeded int64
+	Failed    int64
+
+	// Latency percentiles in seconds. They are 0 when no request completed in the interval.
+	Latency50p float64
+	Latency90p float64
+	Latency99p float64
+	MaxLatency float64
+}
+
+// RPS returns the number of requests completed per second in the interval.
+func (p Point) RPS() float64 {
+	return float64(p.Succeeded+p.Failed) / p.Interval.Seconds()
+}
+
+type seriesBucket struct {
+	succeeded int64
+	failed    int64
+	latencies store
+}
+
+// timeSeries records the requests per interval from the first recorded one.
+type timeSeries struct {
+	interval time.Duration
+	origin   time.Time
+	buckets  []*seriesBucket
+
+	// newStore returns the store for the latencies of an interval.
+	newStore func() store
+}
+
+func newTimeSeries(newStore func() store) timeSeries {
+	return timeSeries{
+		interval: defaultInterval,
+		newStore: newStore,
+	}
+}
+
+func (ts *timeSeries) add(at time.Time, t float64, failed bool) {
+	if ts.interval == 0 {
+		return
+	}
+	if ts.origin.IsZero() {
+		ts.origin = at
+	}
+
+	// The time is taken before the lock, so it can be slightly earlier than the origin.
+	idx := max(int(at.Sub(ts.origin)/ts.interval), 0)
+	for len(ts.buckets) <= idx {
+		ts.buckets = append(ts.buckets, nil)
+	}
+	b := ts.buckets[idx]
+	if b == nil {
+		b = &seriesBucket{latencies: ts.newStore()}
+		ts.buckets[idx] = b
+	}
+
+	if failed {
+		b.failed++
+	} else {
+		b.succeeded++
+	}
+	b.latencies.add(t)
+}
+
+// trim drops the buckets of the intervals which ended before from, and moves the origin to the first remaining one.
+func (ts *timeSeries) trim(from time.Time) {
+	if len(ts.buckets) == 0 {
+		return
+	}
+	n := min(int(from.Sub(ts.origin)/ts.interval), len(ts.buckets))
+	if n <= 0 {
+		return
+	}
+	// Copied rather than resliced, so that the dropped buckets are released.
+	ts.buckets = append([]*seriesBucket(nil), ts.buckets[n:]...)
+	ts.origin = ts.origin.Add(time.Duration(n) * ts.interval)
+}
+
+func (ts *timeSeries) points() []Point {
+	points := make([]Point, 0, len(ts.buckets))
+	for i, b := range ts.buckets {
+		offset := time.Duration(i) * ts.interval
+		p := Point{
+			Time:     ts.origin.Add(offset),
+			Offset:   offset,
+			Interval: ts.interval,
+		}
+		if b != nil {
+			p.Succeeded = b.succeeded
+			p.Failed = b.failed
+			p.Latency50p = b.latencies.percentile(50)
+			p.Latency90p = b.latencies.percentile(90)
+			p.Latency99p = b.latencies.percentile(99)
+			p.MaxLatency = b.latencies.percentile(100)
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+// SetTimeSeriesInterval changes the length of the intervals of TimeSeries (default: 1s).
+// It must be called before anything is recorded.
+func (r *Result) SetTimeSeriesInterval(d time.Duration) error {
+	if d <= 0 {
+		return errors.New("interval must be > 0")
+	}
+
+	r.seriesMu.Lock()
+	defer r.seriesMu.Unlock()
+
+	if len(r.series.buckets) != 0 {
+		return errors.New("time series has already been recorded")
+	}
+	r.series.interval = d
+	return nil
+}
+
+// TimeSeries returns the statistics of each interval from the first recorded request.
+// Intervals in which no request completed are also included with zero values.
+// Results returned by Stage and Corrected do not record the time series, so it returns nil for them.
+func (r *Result) TimeSeries() []Point {
+	r.seriesMu.Lock()
+	defer r.seriesMu.Unlock()
+
+	if len(r.series.buckets) == 0 {
+		return nil
+	}
+	return r.series.points()
+}
+
+func (r *Result) appendSeries(t float64, failed bool) {
+	at := time.Now()
+
+	r.seriesMu.Lock()
+	defer r.seriesMu.Unlock()
+	r.series.add(at, t, failed)
+}
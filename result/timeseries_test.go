@@ -0,0 +1,92 @@
+package result
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeSeries(t *testing.T) {
+	t.Parallel()
+
+	origin := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ts := newTimeSeries(func() store { return newRawStore(0) })
+	ts.add(origin, 0.1, false)
+	ts.add(origin.Add(300*time.Millisecond), 0.3, true)
+	ts.add(origin.Add(-1*time.Millisecond), 0.2, false) // Taken slightly before the origin
+	ts.add(origin.Add(2500*time.Millisecond), 0.5, false)
+
+	want := []Point{
+		{
+			Time:       origin,
+			Offset:     0,
+			Interval:   1 * time.Second,
+			Succeeded:  2,
+			Failed:     1,
+			Latency50p: 0.1,
+			Latency90p: 0.2,
+			Latency99p: 0.2,
+			MaxLatency: 0.3,
+		},
+		{
+			Time:     origin.Add(1 * time.Second),
+			Offset:   1 * time.Second,
+			Interval: 1 * time.Second,
+		},
+		{
+			Time:       origin.Add(2 * time.Second),
+			Offset:     2 * time.Second,
+			Interval:   1 * time.Second,
+			Succeeded:  1,
+			Latency50p: 0.5,
+			Latency90p: 0.5,
+			Latency99p: 0.5,
+			MaxLatency: 0.5,
+		},
+	}
+	points := ts.points()
+	assert.Equal(t, want, points)
+	assert.Equal(t, float64(3), points[0].RPS())
+	assert.Equal(t, float64(0), points[1].RPS())
+}
+
+func TestSetTimeSeriesInterval(t *testing.T) {
+	t.Parallel()
+
+	res, err := New()
+	require.NoError(t, err)
+	assert.Error(t, res.SetTimeSeriesInterval(0))
+	require.NoError(t, res.SetTimeSeriesInterval(100*time.Millisecond))
+
+	res.AppendSuccess(0.1)
+	res.AppendFail(0.2, fmt.Errorf("err"))
+	assert.Error(t, res.SetTimeSeriesInterval(1*time.Second), "must not be changed after recording")
+
+	points := res.TimeSeries()
+	require.Len(t, points, 1)
+	assert.Equal(t, 100*time.Millisecond, points[0].Interval)
+	assert.Equal(t, int64(1), points[0].Succeeded)
+	assert.Equal(t, int64(1), points[0].Failed)
+	assert.Nil(t, res.Stage(0).TimeSeries(), "stage results do not record the time series")
+}
+
+func TestTimeSeriesRawLatencies(t *testing.T) {
+	t.Parallel()
+
+	res, err := WithCapacity(0)
+	require.NoError(t, err)
+	res.AppendSuccess(0.1)
+	assert.IsType(t, &histStore{}, res.series.buckets[0].latencies, "the latencies must not be kept twice")
+
+	data, err := res.MarshalBinary()
+	require.NoError(t, err)
+	got := new(Result)
+	require.NoError(t, got.UnmarshalBinary(data))
+	got.AppendSuccess(0.2)
+	assert.IsType(t, &rawStore{}, got.latencies)
+	assert.IsType(t, &histStore{}, got.series.buckets[0].latencies)
+	assert.Equal(t, seriesPrecision, got.series.newStore().(*histStore).precision)
+}
@@ -0,0 +1,27 @@
+package result
+
+// Timing returns the Result which records the durations of the named part of the requests, such as the DNS lookup of HTTP.
+// Only its latencies are meaningful, and they are recorded by AppendSuccess.
+// It is created on the first call, so the same instance is returned for the same name.
+func (r *Result) Timing(name string) *Result {
+	r.timingsMu.Lock()
+	defer r.timingsMu.Unlock()
+
+	if r.timings == nil {
+		r.timings = make(map[string]*Result)
+	}
+	t, ok := r.timings[name]
+	if !ok {
+		t = r.child()
+		r.timings[name] = t
+		r.timingNames = append(r.timingNames, name)
+	}
+	return t
+}
+
+// Timings returns the names of the timings recorded so far in the order of the first record.
+func (r *Result) Timings() []string {
+	r.timingsMu.Lock()
+	defer r.timingsMu.Unlock()
+	return append([]string(nil), r.timingNames...)
+}
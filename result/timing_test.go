@@ -0,0 +1,26 @@
+package result
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTiming(t *testing.T) {
+	t.Parallel()
+
+	res, err := WithCapacity(0)
+	require.NoError(t, err)
+	assert.Empty(t, res.Timings())
+
+	res.Timing("connect").AppendSuccess(0.002)
+	res.Timing("dns").AppendSuccess(0.001)
+	res.Timing("connect").AppendSuccess(0.004)
+
+	assert.Equal(t, []string{"connect", "dns"}, res.Timings())
+	assert.Same(t, res.Timing("connect"), res.Timing("connect"))
+	assert.Equal(t, []float64{0.002, 0.004}, res.Timing("connect").Latencies())
+	assert.Nil(t, res.Timing("connect").TimeSeries())
+	assert.Zero(t, res.Succeeded(), "timing results must not affect the parent")
+}
@@ -0,0 +1,39 @@
+package result
+
+import "time"
+
+// window is the span from the first dispatch to the last completion of the recorded requests.
+type window struct {
+	start time.Time
+	end   time.Time
+}
+
+// ExtendWindow widens the measurement window to cover a request dispatched at start and completed at end.
+func (r *Result) ExtendWindow(start, end time.Time) {
+	r.windowMu.Lock()
+	defer r.windowMu.Unlock()
+
+	if r.window.start.IsZero() || start.Before(r.window.start) {
+		r.window.start = start
+	}
+	if end.After(r.window.end) {
+		r.window.end = end
+	}
+}
+
+// Window returns when the first recorded request was dispatched and when the last one completed.
+// They are zero when nothing has been recorded by ExtendWindow.
+func (r *Result) Window() (start, end time.Time) {
+	r.windowMu.Lock()
+	defer r.windowMu.Unlock()
+	return r.window.start, r.window.end
+}
+
+// Elapsed returns the length of the measurement window, it is 0 when nothing has been recorded by ExtendWindow.
+func (r *Result) Elapsed() time.Duration {
+	start, end := r.Window()
+	if start.IsZero() {
+		return 0
+	}
+	return end.Sub(start)
+}
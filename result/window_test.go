@@ -0,0 +1,28 @@
+package result
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindow(t *testing.T) {
+	t.Parallel()
+
+	res, err := New()
+	require.NoError(t, err)
+	assert.Zero(t, res.Elapsed())
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	res.ExtendWindow(base.Add(1*time.Second), base.Add(2*time.Second))
+	res.ExtendWindow(base, base.Add(1*time.Second))
+	res.ExtendWindow(base.Add(2*time.Second), base.Add(5*time.Second))
+	res.ExtendWindow(base.Add(3*time.Second), base.Add(4*time.Second))
+
+	start, end := res.Window()
+	assert.Equal(t, base, start)
+	assert.Equal(t, base.Add(5*time.Second), end)
+	assert.Equal(t, 5*time.Second, res.Elapsed())
+}
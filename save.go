@@ -0,0 +1,120 @@
+package otchkiss
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/ryo-yamaoka/otchkiss/setting"
+	"github.com/ryo-yamaoka/otchkiss/threshold"
+)
+
+const (
+	// savedFormat identifies the file saved by Save.
+	savedFormat = "otchkiss"
+
+	// savedVersion is the version of the file saved by Save, it is increased when the file changes incompatibly.
+	savedVersion = 1
+)
+
+// savedRun is the content of the file saved by Save.
+type savedRun struct {
+	Format     string
+	Version    int
+	Setting    setting.Setting
+	Scenarios  []savedScenario
+	Thresholds []threshold.Threshold
+	Result     *result.Result
+}
+
+type savedScenario struct {
+	Name   string
+	Weight int
+}
+
+// Save writes the Setting, the Result, the names of the Scenarios and the Thresholds to w in the gzipped binary,
+// so that the run can be reported again by Load without running the test.
+// Setting.Arrival and the error classifier of the Result are not saved.
+func (ot *Otchkiss) Save(w io.Writer) error {
+	run := savedRun{
+		Format:     savedFormat,
+		Version:    savedVersion,
+		Setting:    *ot.Setting,
+		Thresholds: ot.Thresholds,
+		Result:     ot.Result,
+	}
+	run.Setting.Arrival = nil // The arrival process may not be encodable, and it does not affect the report.
+	for _, sc := range ot.Scenarios {
+		run.Scenarios = append(run.Scenarios, savedScenario{Name: sc.Name, Weight: sc.Weight})
+	}
+
+	zw := gzip.NewWriter(w)
+	if err := gob.NewEncoder(zw).Encode(&run); err != nil {
+		return fmt.Errorf("failed to save: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to save: %w", err)
+	}
+	return nil
+}
+
+// SaveFile writes the run to the file in the same way as Save.
+func (ot *Otchkiss) SaveFile(name string) (err error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, f.Close())
+	}()
+	return ot.Save(f)
+}
+
+// Load returns Otchkiss instance of the run saved by Save.
+// It is only for the reports, such as Report, TemplateReport, JSONReport and Verdict, so Start cannot be called on it.
+// The Scenarios have no Requester, and the sample errors of the Result have only their messages.
+func Load(r io.Reader) (*Otchkiss, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load: %w", err)
+	}
+	defer zr.Close()
+
+	var run savedRun
+	if err := gob.NewDecoder(zr).Decode(&run); err != nil {
+		return nil, fmt.Errorf("failed to load: %w", err)
+	}
+	if run.Format != savedFormat {
+		return nil, errors.New("failed to load: not a saved run of otchkiss")
+	}
+	if run.Version != savedVersion {
+		return nil, fmt.Errorf("failed to load: unsupported version: %d", run.Version)
+	}
+	if run.Result == nil {
+		return nil, errors.New("failed to load: no result")
+	}
+
+	ot := &Otchkiss{
+		Setting:    &run.Setting,
+		Result:     run.Result,
+		Thresholds: run.Thresholds,
+	}
+	for _, sc := range run.Scenarios {
+		ot.Scenarios = append(ot.Scenarios, Scenario{Name: sc.Name, Weight: sc.Weight})
+	}
+	return ot, nil
+}
+
+// LoadFile returns Otchkiss instance of the run saved by SaveFile.
+func LoadFile(name string) (*Otchkiss, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
@@ -0,0 +1,120 @@
+package otchkiss
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ryo-yamaoka/otchkiss/arrival"
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/ryo-yamaoka/otchkiss/setting"
+	"github.com/ryo-yamaoka/otchkiss/threshold"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveLoad(t *testing.T) {
+	t.Parallel()
+
+	r, err := result.New()
+	require.NoError(t, err)
+	r.AppendSuccess(0.1)
+	r.AppendSuccess(0.2)
+	r.AppendFail(0.3, errors.New("err1"))
+	r.Scenario("search").AppendSuccess(0.1)
+	r.Tagged(result.Tag{Key: "endpoint", Value: "search"}).AppendSuccess(0.1)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.ExtendWindow(base, base.Add(2*time.Second))
+
+	ot := &Otchkiss{
+		Scenarios: []Scenario{{Name: "search", Weight: 3, Requester: &testRequesterImpl{}}},
+		Setting: &setting.Setting{
+			MaxConcurrent: 1,
+			MaxRPS:        10,
+			RunDuration:   2 * time.Second,
+			Arrival:       arrival.NewPoisson(1),
+		},
+		Result:     r,
+		Thresholds: []threshold.Threshold{{Metric: threshold.Percentile(99), Op: threshold.Less, Value: 0.25}},
+	}
+	path := filepath.Join(t.TempDir(), "run.otchkiss")
+	require.NoError(t, ot.SaveFile(path))
+	loaded, err := LoadFile(path)
+	require.NoError(t, err)
+
+	assert.Nil(t, loaded.Setting.Arrival)
+	assert.Equal(t, []Scenario{{Name: "search", Weight: 3}}, loaded.Scenarios)
+	assert.Equal(t, ot.Thresholds, loaded.Thresholds)
+
+	want, err := ot.Report()
+	require.NoError(t, err)
+	got, err := loaded.Report()
+	require.NoError(t, err)
+	assert.Empty(t, cmp.Diff(want, got))
+
+	wantJSON, err := ot.JSONReport()
+	require.NoError(t, err)
+	gotJSON, err := loaded.JSONReport()
+	require.NoError(t, err)
+	assert.JSONEq(t, string(wantJSON), string(gotJSON))
+
+	assert.Error(t, loaded.Start(context.Background()), "the loaded run has no Requester")
+}
+
+func TestSaveLoadSeriesGap(t *testing.T) {
+	t.Parallel()
+
+	ot, err := FromConfig(&slowRequesterImpl{latency: 30 * time.Millisecond}, &setting.Setting{MaxConcurrent: 1, Iterations: 3}, 3)
+	require.NoError(t, err)
+	require.NoError(t, ot.Result.SetTimeSeriesInterval(10*time.Millisecond))
+	require.NoError(t, ot.Start(context.Background()))
+
+	points := ot.Result.TimeSeries()
+	require.Greater(t, len(points), 3)
+	assert.Equal(t, int64(0), points[1].Succeeded+points[1].Failed, "the requests are slower than the interval")
+
+	path := filepath.Join(t.TempDir(), "run.otchkiss")
+	require.NoError(t, ot.SaveFile(path))
+	loaded, err := LoadFile(path)
+	require.NoError(t, err)
+	for i := range points {
+		points[i].Time = points[i].Time.Round(0) // The monotonic clock reading is not saved.
+	}
+	assert.Equal(t, points, loaded.Result.TimeSeries())
+}
+
+func TestLoadInvalid(t *testing.T) {
+	t.Parallel()
+
+	var other bytes.Buffer
+	zw := gzip.NewWriter(&other)
+	_, err := zw.Write([]byte("not a gob"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	testCases := map[string]struct {
+		data []byte
+	}{
+		"not gzip": {
+			data: []byte("invalid"),
+		},
+		"not gob": {
+			data: other.Bytes(),
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Load(bytes.NewReader(tc.data))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package otchkiss
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/ryo-yamaoka/otchkiss/setting"
+)
+
+// Scenario is a named Requester which runs in proportion to its Weight among the scenarios.
+// For example, the weights 70, 25 and 5 make 70% browse, 25% search and 5% checkout.
+type Scenario struct {
+	Name      string
+	Weight    int
+	Requester Requester
+}
+
+// NewScenarios returns Otchkiss instance which runs the given scenarios with default setting.
+// Each iteration picks one of the scenarios at random by their weights.
+// The command line arguments are parsed in the same way as New.
+func NewScenarios(scenarios ...Scenario) (*Otchkiss, error) {
+	s, err := setting.FromDefaultFlag()
+	if err != nil {
+		return nil, err
+	}
+	r, err := result.New()
+	if err != nil {
+		return nil, err
+	}
+
+	return newOtchkiss(nil, scenarios, s, r)
+}
+
+// FromScenarios returns Otchkiss instance which runs the given scenarios by user specified setting.
+// Each iteration picks one of the scenarios at random by their weights.
+// resultCapacity is the same as FromConfig.
+func FromScenarios(setting *setting.Setting, resultCapacity int, scenarios ...Scenario) (*Otchkiss, error) {
+	r, err := result.WithCapacity(resultCapacity)
+	if err != nil {
+		return nil, err
+	}
+	return newOtchkiss(nil, scenarios, setting, r)
+}
+
+func validateScenarios(scenarios []Scenario) error {
+	names := make(map[string]struct{}, len(scenarios))
+	for _, sc := range scenarios {
+		if sc.Name == "" {
+			return errors.New("empty scenario name")
+		}
+		if _, ok := names[sc.Name]; ok {
+			return fmt.Errorf("duplicate scenario name: %s", sc.Name)
+		}
+		names[sc.Name] = struct{}{}
+		if !(sc.Weight > 0) {
+			return fmt.Errorf("weight of scenario %s must be > 0", sc.Name)
+		}
+		if sc.Requester == nil {
+			return fmt.Errorf("nil requester of scenario %s", sc.Name)
+		}
+	}
+	return nil
+}
+
+// scenarios returns Scenarios, or the single scenario of Requester if they are not specified.
+func (ot *Otchkiss) scenarios() []Scenario {
+	if len(ot.Scenarios) != 0 {
+		return ot.Scenarios
+	}
+	return []Scenario{{Weight: 1, Requester: ot.Requester}}
+}
+
+func requestersOf(scenarios []Scenario) []Requester {
+	requesters := make([]Requester, 0, len(scenarios))
+	for _, sc := range scenarios {
+		requesters = append(requesters, sc.Requester)
+	}
+	return requesters
+}
+
+// scenarioPicker picks a scenario at random by the weights.
+// It is not thread safe, so only the dispatching goroutine uses it.
+type scenarioPicker struct {
+	scenarios  []Scenario
+	cumulative []int
+	rnd        *rand.Rand
+}
+
+func newScenarioPicker(scenarios []Scenario) *scenarioPicker {
+	cumulative := make([]int, len(scenarios))
+	var total int
+	for i, sc := range scenarios {
+		total += sc.Weight
+		cumulative[i] = total
+	}
+	return &scenarioPicker{
+		scenarios:  scenarios,
+		cumulative: cumulative,
+		rnd:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (sp *scenarioPicker) pick() Scenario {
+	if len(sp.scenarios) == 1 {
+		return sp.scenarios[0]
+	}
+
+	n := sp.rnd.Intn(sp.cumulative[len(sp.cumulative)-1])
+	for i, c := range sp.cumulative {
+		if n < c {
+			return sp.scenarios[i]
+		}
+	}
+	return sp.scenarios[len(sp.scenarios)-1]
+}
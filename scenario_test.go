@@ -0,0 +1,143 @@
+package otchkiss
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingRequesterImpl struct {
+	testRequesterImpl
+	initErr    error
+	inits      int
+	terminates int
+}
+
+func (cr *countingRequesterImpl) Init() error {
+	cr.inits++
+	return cr.initErr
+}
+
+func (cr *countingRequesterImpl) Terminate() error {
+	cr.terminates++
+	return nil
+}
+
+func TestFromScenarios(t *testing.T) {
+	t.Parallel()
+
+	st := &setting.Setting{RunDuration: 1 * time.Second}
+	testCases := map[string]struct {
+		scenarios []Scenario
+		wantError assert.ErrorAssertionFunc
+	}{
+		"ok": {
+			scenarios: []Scenario{
+				{Name: "browse", Weight: 70, Requester: &testRequesterImpl{}},
+				{Name: "search", Weight: 30, Requester: &testRequesterImpl{}},
+			},
+			wantError: assert.NoError,
+		},
+		"ng: no scenario": {
+			scenarios: nil,
+			wantError: assert.Error,
+		},
+		"ng: empty name": {
+			scenarios: []Scenario{{Name: "", Weight: 1, Requester: &testRequesterImpl{}}},
+			wantError: assert.Error,
+		},
+		"ng: duplicate name": {
+			scenarios: []Scenario{
+				{Name: "browse", Weight: 1, Requester: &testRequesterImpl{}},
+				{Name: "browse", Weight: 1, Requester: &testRequesterImpl{}},
+			},
+			wantError: assert.Error,
+		},
+		"ng: zero weight": {
+			scenarios: []Scenario{{Name: "browse", Weight: 0, Requester: &testRequesterImpl{}}},
+			wantError: assert.Error,
+		},
+		"ng: nil requester": {
+			scenarios: []Scenario{{Name: "browse", Weight: 1, Requester: nil}},
+			wantError: assert.Error,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			ot, err := FromScenarios(st, 0, tc.scenarios...)
+			tc.wantError(t, err)
+			if err == nil {
+				assert.Equal(t, tc.scenarios, ot.Scenarios)
+				assert.Nil(t, ot.Requester)
+			}
+		})
+	}
+}
+
+func TestScenarioPicker(t *testing.T) {
+	t.Parallel()
+
+	sp := newScenarioPicker([]Scenario{
+		{Name: "browse", Weight: 70},
+		{Name: "search", Weight: 25},
+		{Name: "checkout", Weight: 5},
+	})
+	counts := map[string]int{}
+	const n = 100000
+	for i := 0; i < n; i++ {
+		counts[sp.pick().Name]++
+	}
+	assert.InDelta(t, 0.70, float64(counts["browse"])/n, 0.01)
+	assert.InDelta(t, 0.25, float64(counts["search"])/n, 0.01)
+	assert.InDelta(t, 0.05, float64(counts["checkout"])/n, 0.01)
+}
+
+func TestStartScenarios(t *testing.T) {
+	t.Parallel()
+
+	browse, search := &countingRequesterImpl{}, &countingRequesterImpl{}
+	ot, err := FromScenarios(&setting.Setting{
+		MaxConcurrent: 1,
+		RunDuration:   1 * time.Second,
+		Arrival:       mustReplay(t, 1000),
+	}, 1000, Scenario{Name: "browse", Weight: 3, Requester: browse}, Scenario{Name: "search", Weight: 1, Requester: search})
+	require.NoError(t, err)
+	require.NoError(t, ot.Start(context.Background()))
+
+	assert.Equal(t, 1, browse.inits)
+	assert.Equal(t, 1, browse.terminates)
+	assert.Equal(t, 1, search.inits)
+	assert.Equal(t, 1, search.terminates)
+
+	require.Equal(t, int64(1000), ot.Result.Succeeded())
+	assert.ElementsMatch(t, []string{"browse", "search"}, ot.Result.Scenarios())
+	assert.InDelta(t, 750, ot.Result.Scenario("browse").Succeeded(), 60)
+	assert.Equal(t, int64(1000), ot.Result.Scenario("browse").Succeeded()+ot.Result.Scenario("search").Succeeded())
+
+	report, err := ot.Report()
+	require.NoError(t, err)
+	assert.Contains(t, report, "[Scenario: browse]\n* weight: 3, share: ")
+	assert.Contains(t, report, "[Scenario: search]\n* weight: 1, share: ")
+}
+
+func TestStartScenariosInitError(t *testing.T) {
+	t.Parallel()
+
+	browse, search := &countingRequesterImpl{}, &countingRequesterImpl{initErr: errors.New("init")}
+	ot, err := FromScenarios(&setting.Setting{RunDuration: 1 * time.Second}, 0,
+		Scenario{Name: "browse", Weight: 1, Requester: browse}, Scenario{Name: "search", Weight: 1, Requester: search})
+	require.NoError(t, err)
+
+	assert.Error(t, ot.Start(context.Background()))
+	assert.Equal(t, 1, browse.terminates, "initialized scenarios must be terminated")
+	assert.Equal(t, 0, search.terminates)
+}
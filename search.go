@@ -0,0 +1,210 @@
+package otchkiss
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/ryo-yamaoka/otchkiss/threshold"
+
+	humanize "github.com/dustin/go-humanize"
+)
+
+// Search defines how Otchkiss.Search looks for the max sustainable RPS.
+type Search struct {
+	// MinRPS and MaxRPS define the range of the target RPS to try.
+	MinRPS int
+	MaxRPS int
+
+	// Step defines the increment of the target RPS from MinRPS, and the search stops at the first level which fails.
+	// The last level is MaxRPS even when it is not a multiple of Step from MinRPS.
+	// 0 means the binary search between MinRPS and MaxRPS.
+	Step int
+
+	// Resolution defines the precision of the binary search, it stops when the range is narrower than it.
+	// 0 means 1.
+	Resolution int
+
+	// LevelDuration defines how long each level is measured.
+	LevelDuration time.Duration
+
+	// MinAchieved defines the min ratio of the actual RPS to the target RPS for a level to pass, for example 0.9 means 90%.
+	// 0 means the actual RPS is not checked.
+	MinAchieved float64
+}
+
+func (s *Search) validate() error {
+	if !(s.MinRPS > 0) {
+		return errors.New("min RPS must be > 0")
+	}
+	if !(s.MaxRPS >= s.MinRPS) {
+		return errors.New("max RPS must be >= min RPS")
+	}
+	if !(s.Step >= 0) {
+		return errors.New("step must be >= 0")
+	}
+	if !(s.Resolution >= 0) {
+		return errors.New("resolution must be >= 0")
+	}
+	if !(s.LevelDuration > 0) {
+		return errors.New("level duration must be > 0 sec")
+	}
+	if !(s.MinAchieved >= 0 && s.MinAchieved <= 1) {
+		return errors.New("min achieved must be between 0 and 1")
+	}
+	return nil
+}
+
+// SearchLevel is the outcome of a target RPS which Search tried.
+type SearchLevel struct {
+	TargetRPS int
+	Passed    bool
+
+	// Achieved is the ratio of the actual RPS to TargetRPS.
+	Achieved float64
+
+	// Verdict is the outcome of Otchkiss.Thresholds at the level.
+	Verdict threshold.Verdict
+
+	// Aborted is not nil when one of Otchkiss.AbortConditions failed at the level.
+	Aborted *ErrAborted
+
+	Result *result.Result
+}
+
+// SearchResult is the outcome of Otchkiss.Search.
+type SearchResult struct {
+	// MaxRPS is the highest target RPS which passed, it is 0 when no level passed.
+	MaxRPS int
+
+	// Levels are every level in the order tried.
+	Levels []SearchLevel
+}
+
+// Search runs the test at several target RPS, and finds the highest one which satisfies Thresholds.
+// Each level is a separate Start with MaxRPS of the level and RunDuration of LevelDuration, so it has its own warm up, Init() and Terminate().
+// Stages and iterations of the Setting are ignored, and the Result of each level records in the same way as Otchkiss.Result, including its error classifier.
+// Even if it returns an error, the levels tried so far are returned.
+func (ot *Otchkiss) Search(ctx context.Context, s Search) (*SearchResult, error) {
+	if err := s.validate(); err != nil {
+		return nil, fmt.Errorf("invalid search: %w", err)
+	}
+	if len(ot.Thresholds) == 0 && s.MinAchieved == 0 {
+		return nil, errors.New("invalid search: thresholds or min achieved is required to judge the levels")
+	}
+
+	sr := &SearchResult{}
+	try := func(rps int) (bool, error) {
+		level, err := ot.runLevel(ctx, s, rps)
+		if err != nil {
+			return false, fmt.Errorf("failed to run %d RPS: %w", rps, err)
+		}
+		sr.Levels = append(sr.Levels, *level)
+		if level.Passed {
+			sr.MaxRPS = max(sr.MaxRPS, rps)
+		}
+		return level.Passed, nil
+	}
+
+	if s.Step > 0 {
+		for rps := s.MinRPS; ; rps = min(rps+s.Step, s.MaxRPS) {
+			passed, err := try(rps)
+			if err != nil {
+				return sr, err
+			}
+			if !passed || rps == s.MaxRPS {
+				break
+			}
+		}
+		return sr, nil
+	}
+
+	resolution := max(s.Resolution, 1)
+	lo, hi := s.MinRPS, s.MaxRPS
+	for lo <= hi {
+		mid := lo + (hi-lo)/2
+		passed, err := try(mid)
+		if err != nil {
+			return sr, err
+		}
+		if passed {
+			lo = mid + resolution
+		} else {
+			hi = mid - resolution
+		}
+	}
+	return sr, nil
+}
+
+// runLevel runs the test at the target RPS.
+func (ot *Otchkiss) runLevel(ctx context.Context, s Search, rps int) (*SearchLevel, error) {
+	st := *ot.Setting
+	st.MaxRPS = rps
+	st.RunDuration = s.LevelDuration
+	st.Stages = nil
+	st.Iterations = 0
+	st.IterationsPerVU = 0
+	st.MaxDuration = 0
+
+	r := ot.Result.Empty()
+	lot := &Otchkiss{
+		Requester:       ot.Requester,
+		Scenarios:       ot.Scenarios,
+		Factory:         ot.Factory,
+		Setting:         &st,
+		Result:          r,
+		Thresholds:      ot.Thresholds,
+		AbortConditions: ot.AbortConditions,
+	}
+
+	level := &SearchLevel{TargetRPS: rps, Result: r}
+	if err := lot.Start(ctx); err != nil && !errors.As(err, &level.Aborted) {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	level.Verdict = lot.Verdict()
+	level.Achieved = float64(r.Succeeded()+r.Failed()) / lot.measuredDuration().Seconds() / float64(rps)
+	level.Passed = level.Aborted == nil && level.Verdict.Passed && level.Achieved >= s.MinAchieved
+	return level, nil
+}
+
+// Report returns the max sustainable RPS and the table of the levels.
+func (sr *SearchResult) Report() (string, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "\n[Search]\n* max sustainable RPS: %s\n\n", humanize.Comma(int64(sr.MaxRPS)))
+
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "target RPS\tRPS\terror rate\tp99\tresult\t")
+	for _, l := range sr.Levels {
+		total := l.Result.Succeeded() + l.Result.Failed()
+		errorRate, p99 := "-", "-"
+		if total != 0 {
+			errorRate = humanize.CommafWithDigits(float64(l.Result.Failed())/float64(total)*100, 1) + " %"
+			if v, err := l.Result.PercentileLatency(99); err == nil {
+				p99 = humanize.CommafWithDigits(v*1000, 1) + " ms"
+			}
+		}
+		res := passOrFail(l.Passed)
+		if l.Aborted != nil {
+			res += " (aborted)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n",
+			humanize.Comma(int64(l.TargetRPS)),
+			humanize.CommafWithDigits(l.Achieved*float64(l.TargetRPS), 1),
+			errorRate,
+			p99,
+			res,
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,138 @@
+package otchkiss
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/setting"
+	"github.com/ryo-yamaoka/otchkiss/threshold"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rateLimitedRequesterImpl fails when it is called more than limit times in 100ms, like a target which breaks above its capacity.
+type rateLimitedRequesterImpl struct {
+	testRequesterImpl
+	limit int
+
+	mu     sync.Mutex
+	recent []time.Time
+}
+
+// Init forgets the requests of the previous level, as if the target recovered between the levels.
+func (rr *rateLimitedRequesterImpl) Init() error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.recent = nil
+	return nil
+}
+
+func (rr *rateLimitedRequesterImpl) RequestOne(_ context.Context) error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	now := time.Now()
+	for len(rr.recent) != 0 && now.Sub(rr.recent[0]) > 100*time.Millisecond {
+		rr.recent = rr.recent[1:]
+	}
+	rr.recent = append(rr.recent, now)
+	if len(rr.recent) > rr.limit {
+		return errors.New("overloaded")
+	}
+	return nil
+}
+
+func newSearchOtchkiss(t *testing.T) *Otchkiss {
+	t.Helper()
+
+	ot, err := FromConfig(&rateLimitedRequesterImpl{limit: 15}, &setting.Setting{MaxConcurrent: 1}, 0)
+	require.NoError(t, err)
+	ot.Thresholds = []threshold.Threshold{{Metric: threshold.ErrorRate, Op: threshold.Less, Value: 5}}
+	return ot
+}
+
+func TestSearchStep(t *testing.T) {
+	t.Parallel()
+
+	ot := newSearchOtchkiss(t)
+	sr, err := ot.Search(context.Background(), Search{MinRPS: 50, MaxRPS: 400, Step: 50, LevelDuration: 300 * time.Millisecond})
+	require.NoError(t, err)
+
+	// The capacity is 150 RPS, so 100 RPS passes and 200 RPS fails.
+	assert.GreaterOrEqual(t, sr.MaxRPS, 100)
+	assert.Less(t, sr.MaxRPS, 200)
+	last := sr.Levels[len(sr.Levels)-1]
+	assert.False(t, last.Passed, "the search stops at the first failed level")
+	assert.Equal(t, sr.MaxRPS+50, last.TargetRPS)
+	for _, l := range sr.Levels[:len(sr.Levels)-1] {
+		assert.True(t, l.Passed)
+	}
+
+	report, err := sr.Report()
+	require.NoError(t, err)
+	assert.Contains(t, report, "* max sustainable RPS: ")
+	assert.Contains(t, report, "target RPS  RPS")
+}
+
+func TestSearchStepMaxRPS(t *testing.T) {
+	t.Parallel()
+
+	ot := newSearchOtchkiss(t)
+	ot.Result.SetErrorClassifier(func(error) string { return "classified" })
+	sr, err := ot.Search(context.Background(), Search{MinRPS: 100, MaxRPS: 300, Step: 500, LevelDuration: 300 * time.Millisecond})
+	require.NoError(t, err)
+
+	require.Len(t, sr.Levels, 2)
+	assert.Equal(t, 100, sr.Levels[0].TargetRPS)
+	assert.True(t, sr.Levels[0].Passed)
+	assert.Equal(t, 300, sr.Levels[1].TargetRPS, "MaxRPS is tried even when it is not on a step")
+	assert.False(t, sr.Levels[1].Passed)
+	groups := sr.Levels[1].Result.ErrorGroups()
+	require.NotEmpty(t, groups)
+	assert.Equal(t, "classified", groups[0].Key, "the level keeps the classifier of Otchkiss.Result")
+}
+
+func TestSearchBinary(t *testing.T) {
+	t.Parallel()
+
+	ot := newSearchOtchkiss(t)
+	sr, err := ot.Search(context.Background(), Search{MinRPS: 25, MaxRPS: 400, Resolution: 25, LevelDuration: 300 * time.Millisecond})
+	require.NoError(t, err)
+
+	// The capacity is 150 RPS, and the levels around it are tried.
+	assert.GreaterOrEqual(t, sr.MaxRPS, 100)
+	assert.Less(t, sr.MaxRPS, 187)
+	assert.Greater(t, len(sr.Levels), 2)
+	assert.Equal(t, 212, sr.Levels[0].TargetRPS)
+	assert.False(t, sr.Levels[0].Passed)
+}
+
+func TestSearchInvalid(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]Search{
+		"min RPS":        {MinRPS: 0, MaxRPS: 10, LevelDuration: 1 * time.Second},
+		"max RPS":        {MinRPS: 10, MaxRPS: 5, LevelDuration: 1 * time.Second},
+		"step":           {MinRPS: 1, MaxRPS: 10, Step: -1, LevelDuration: 1 * time.Second},
+		"level duration": {MinRPS: 1, MaxRPS: 10},
+		"min achieved":   {MinRPS: 1, MaxRPS: 10, LevelDuration: 1 * time.Second, MinAchieved: 2},
+	}
+
+	for tn, s := range testCases {
+		s := s
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := newSearchOtchkiss(t).Search(context.Background(), s)
+			assert.Error(t, err)
+		})
+	}
+
+	ot, err := FromConfig(&testRequesterImpl{}, &setting.Setting{}, 0)
+	require.NoError(t, err)
+	_, err = ot.Search(context.Background(), Search{MinRPS: 1, MaxRPS: 10, LevelDuration: 1 * time.Second})
+	assert.Error(t, err, "no SLO to judge the levels")
+}
@@ -1,25 +1,37 @@
+// Acquire, Release and notifyWaiters are derived from golang.org/x/sync/semaphore,
+// extended to allow the unlimited weight and changing the limit while in use.
+//
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the NOTICE file.
+
 package sema
 
 import (
+	"container/list"
 	"context"
-
-	"golang.org/x/sync/semaphore"
+	"sync"
 )
 
-// Sema implements semaphore with it can be unlimited by specifying 0
+// Sema implements semaphore with it can be unlimited by specifying 0.
+// Unlike golang.org/x/sync/semaphore, the maximum combined weight can be changed while it is in use.
 type Sema struct {
-	sem *semaphore.Weighted
+	mu      sync.Mutex
+	size    int64
+	cur     int64
+	waiters list.List
+}
+
+type waiter struct {
+	n     int64
+	ready chan<- struct{}
 }
 
 // NewWeighted creates a new weighted semaphore with the given maximum combined weight for concurrent access.
 // When you specify 0, it means unlimited concurrent access.
 func NewWeighted(n int64) *Sema {
-	var sem *semaphore.Weighted
-	if n != 0 {
-		sem = semaphore.NewWeighted(n)
-	}
 	return &Sema{
-		sem: sem,
+		size: n,
 	}
 }
 
@@ -27,17 +39,125 @@ func NewWeighted(n int64) *Sema {
 // If ctx is already done, Acquire may still succeed without blocking.
 // If you created semaphore with 0, this method is non blocking.
 func (s *Sema) Acquire(ctx context.Context, n int64) error {
-	if s.sem == nil {
+	done := ctx.Done()
+
+	s.mu.Lock()
+	if s.size == 0 {
+		s.mu.Unlock()
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+	select {
+	case <-done:
+		s.mu.Unlock()
 		return ctx.Err()
+	default:
+	}
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(waiter{n: n, ready: ready})
+	s.mu.Unlock()
+
+	select {
+	case <-done:
+		s.mu.Lock()
+		select {
+		case <-ready:
+			// Acquired the semaphore after we were canceled.
+			// Pretend we didn't and put the tokens back.
+			s.cur -= n
+			s.notifyWaiters()
+		default:
+			isFront := s.waiters.Front() == elem
+			s.waiters.Remove(elem)
+			// If we're at the front and there are extra tokens left, notify other waiters.
+			if isFront && s.size > s.cur {
+				s.notifyWaiters()
+			}
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+
+	case <-ready:
+		// Acquired the semaphore. Check that ctx isn't already done.
+		// We check the done channel instead of calling ctx.Err because we
+		// already have the channel, and ctx.Err is O(n) with the nesting
+		// depth of ctx.
+		select {
+		case <-done:
+			s.Release(n)
+			return ctx.Err()
+		default:
+		}
+		return nil
 	}
-	return s.sem.Acquire(ctx, n)
 }
 
 // Release releases the semaphore with a weight of n.
-// But if you created semaphore with 0, this method is no effect.
+// But if you created semaphore with 0, this method only decreases the number of holders.
 func (s *Sema) Release(n int64) {
-	if s.sem == nil {
-		return
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cur -= n
+	if s.cur < 0 {
+		panic("sema: released more than held")
+	}
+	s.notifyWaiters()
+}
+
+// SetLimit changes the maximum combined weight to n. 0 means unlimited.
+// When the limit is reduced, current holders are not affected but new Acquire calls block until enough is released.
+func (s *Sema) SetLimit(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.size = n
+	s.notifyWaiters()
+}
+
+// Limit returns the current maximum combined weight. 0 means unlimited.
+func (s *Sema) Limit() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// Holding returns the combined weight currently acquired.
+func (s *Sema) Holding() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cur
+}
+
+func (s *Sema) notifyWaiters() {
+	for {
+		next := s.waiters.Front()
+		if next == nil {
+			break // No more waiters blocked.
+		}
+
+		w := next.Value.(waiter)
+		if s.size != 0 && s.size-s.cur < w.n {
+			// Not enough tokens for the next waiter. We could keep going (to try to
+			// find a waiter with a smaller request), but under load that could cause
+			// starvation for large requests; instead, we leave all remaining waiters
+			// blocked.
+			break
+		}
+
+		s.cur += w.n
+		s.waiters.Remove(next)
+		close(w.ready)
 	}
-	s.sem.Release(n)
 }
@@ -0,0 +1,94 @@
+package sema
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquire(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		size        int64
+		acquire     int
+		wantError   assert.ErrorAssertionFunc
+		wantHolding int64
+	}{
+		"within limit": {
+			size:        2,
+			acquire:     2,
+			wantError:   assert.NoError,
+			wantHolding: 2,
+		},
+		"exceed limit": {
+			size:        2,
+			acquire:     3,
+			wantError:   assert.Error,
+			wantHolding: 2,
+		},
+		"unlimited": {
+			size:        0,
+			acquire:     100,
+			wantError:   assert.NoError,
+			wantHolding: 100,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			s := NewWeighted(tc.size)
+			var err error
+			for i := 0; i < tc.acquire && err == nil; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+				err = s.Acquire(ctx, 1)
+				cancel()
+			}
+			tc.wantError(t, err)
+			assert.Equal(t, tc.wantHolding, s.Holding())
+		})
+	}
+}
+
+func TestSetLimit(t *testing.T) {
+	t.Parallel()
+
+	s := NewWeighted(1)
+	require.NoError(t, s.Acquire(context.Background(), 1))
+
+	acquired := make(chan struct{})
+	go func() {
+		_ = s.Acquire(context.Background(), 1)
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquired over the limit")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	s.SetLimit(2)
+	select {
+	case <-acquired:
+	case <-time.After(1 * time.Second):
+		t.Fatal("not acquired after the limit was raised")
+	}
+	assert.Equal(t, int64(2), s.Limit())
+	assert.Equal(t, int64(2), s.Holding())
+
+	s.SetLimit(1)
+	s.Release(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, s.Acquire(ctx, 1), "must block until holding falls below the lowered limit")
+
+	s.Release(1)
+	assert.NoError(t, s.Acquire(context.Background(), 1))
+}
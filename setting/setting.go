@@ -5,6 +5,8 @@ import (
 	"flag"
 	"os"
 	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/arrival"
 )
 
 const (
@@ -12,6 +14,12 @@ const (
 	defaultRunDuration   = 5 * time.Second
 	defaultWarmUpTime    = 5 * time.Second
 	defaultMaxRPS        = 1
+
+	// defaultMaxWorkers bounds the workers when the concurrency is unlimited.
+	defaultMaxWorkers = 10000
+	defaultMaxBacklog = 1000
+
+	defaultStabilityWindow = 100
 )
 
 type Setting struct {
@@ -22,16 +30,297 @@ type Setting struct {
 
 	// RunDuration defines how long RequestOne should continue to run.
 	// During the time specified here, the request results are included in the Result.
+	// It is ignored when Iterations or IterationsPerVU is specified.
 	RunDuration time.Duration
 
+	// Iterations defines how many requests are included in the Result, and the test ends when they complete.
+	// 0 means the measurement lasts for RunDuration.
+	Iterations int
+
+	// IterationsPerVU defines how many requests each virtual user includes in the Result.
+	// It is only for the virtual users, and can be combined with Iterations.
+	// The virtual users which finished their iterations stop without the cool down.
+	IterationsPerVU int
+
+	// MaxDuration caps the measurement when Iterations or IterationsPerVU is specified.
+	// 0 means no limit.
+	MaxDuration time.Duration
+
 	// WarmUpTime defines how long RequestOne not included in the measurement should continue to run.
 	// During the time specified here, the request results are NOT included in the Result.
+	// It is ignored when WarmUpRequests or WarmUpStability is specified.
 	WarmUpTime time.Duration
 
+	// WarmUpRequests defines how many requests are sent as warm up instead of WarmUpTime.
+	// 0 means the warm up is defined by WarmUpTime or WarmUpStability.
+	WarmUpRequests int
+
+	// WarmUpStability makes the warm up last until the latency percentiles settle instead of WarmUpTime.
+	// nil means the warm up is defined by WarmUpTime or WarmUpRequests.
+	WarmUpStability *Stability
+
+	// CoolDownTime defines how long RequestOne continues to run after the measurement.
+	// The requests sent during the time are NOT included in the Result, so the requests still in flight at the end of the measurement complete normally.
+	// 0 means the test ends right after the measurement.
+	CoolDownTime time.Duration
+
 	// MaxRPS defines how much can request per second.
 	// 0 means unlimited.
 	// MaxConcurrent or MaxRPS, whichever is smaller blocks the request.
 	MaxRPS int
+
+	// Stages defines the load profile of the measurement.
+	// When it is specified, RunDuration is ignored and the measurement lasts for the total duration of the stages.
+	// MaxConcurrent and MaxRPS are used during warm up and as the starting point of the first linear ramp.
+	Stages []Stage
+
+	// StageMode defines how the targets of Stages are reached.
+	StageMode StageMode
+
+	// OpenModel defines whether RequestOne is started at fixed intended start times.
+	// By default, the next request waits for MaxConcurrent (closed model), so fewer requests are sent when the target slows down.
+	// In the open model, requests are scheduled by MaxRPS (and TargetRPS of Stages) regardless of the response,
+	// and the latency from the intended start is recorded as the corrected latency in addition to the actual one.
+	// MaxRPS (and TargetRPS of Stages) must be > 0.
+	OpenModel bool
+
+	// Arrival defines the intervals between the requests at MaxRPS (and TargetRPS of Stages).
+	// nil means arrival.Constant, which spaces requests evenly.
+	Arrival arrival.Process
+
+	// VirtualUsers defines how many virtual users run when Otchkiss runs a RequesterFactory.
+	// 0 means the same as MaxConcurrent.
+	VirtualUsers int
+
+	// Workers defines how many workers call RequestOne.
+	// 0 means elastic, workers are added as needed up to the peak concurrency (or 10000 if it is unlimited).
+	Workers int
+
+	// MaxBacklog defines how many scheduled requests can wait for a worker.
+	// When it is full, the closed model waits for room, and the open model drops the request and counts it as dropped.
+	// 0 means 1000.
+	MaxBacklog int
+}
+
+// Stage defines a period of the load profile.
+type Stage struct {
+	// Duration defines how long the stage lasts.
+	Duration time.Duration
+
+	// TargetRPS defines the max request per second of the stage.
+	// 0 means unlimited.
+	TargetRPS int
+
+	// TargetConcurrent defines how many RequestOne should concurrently running in the stage.
+	// 0 means unlimited.
+	TargetConcurrent int
+}
+
+// Stability defines when the latencies are regarded as settled.
+// The percentiles of the latest Window requests are compared with the ones of the Window requests before them,
+// and they are settled when every percentile changes within Tolerance.
+type Stability struct {
+	// Window defines how many requests each of the compared windows has.
+	// 0 means 100.
+	Window int
+
+	// Tolerance defines the max relative change of the percentiles, for example 0.1 means 10%.
+	Tolerance float64
+
+	// Percentiles defines the compared percentiles.
+	// nil means the median and the 99th percentile.
+	Percentiles []int
+
+	// MaxTime caps the warm up even if the latencies do not settle.
+	// 0 means no limit.
+	MaxTime time.Duration
+}
+
+// WindowSize returns Window, or the default if it is not specified.
+func (st *Stability) WindowSize() int {
+	if st.Window > 0 {
+		return st.Window
+	}
+	return defaultStabilityWindow
+}
+
+// ComparedPercentiles returns Percentiles, or the default if they are not specified.
+func (st *Stability) ComparedPercentiles() []int {
+	if len(st.Percentiles) != 0 {
+		return st.Percentiles
+	}
+	return []int{50, 99}
+}
+
+// ValidateStability checks the given stability is usable, nil is valid.
+func ValidateStability(st *Stability) error {
+	if st == nil {
+		return nil
+	}
+	if !(st.Window >= 0) {
+		return errors.New("stability window must be >= 0")
+	}
+	if !(st.Tolerance > 0) {
+		return errors.New("stability tolerance must be > 0")
+	}
+	for _, p := range st.Percentiles {
+		if p < 0 || p > 100 {
+			return errors.New("stability percentiles must be between 0 and 100")
+		}
+	}
+	if !(st.MaxTime >= 0*time.Second) {
+		return errors.New("stability max time must be >= 0 sec")
+	}
+	return nil
+}
+
+// StageMode defines how the targets of the stages are reached.
+type StageMode int
+
+const (
+	// StageModeStep switches to the targets as soon as the stage begins.
+	StageModeStep StageMode = iota
+
+	// StageModeLinear moves linearly from the previous targets to the targets of the stage over its duration.
+	// If either of the values is 0 (unlimited), the target is switched at the end of the stage instead.
+	StageModeLinear
+)
+
+// VirtualUserCount returns the number of the virtual users, it is MaxConcurrent if VirtualUsers is not specified.
+func (s *Setting) VirtualUserCount() int {
+	if s.VirtualUsers > 0 {
+		return s.VirtualUsers
+	}
+	return s.MaxConcurrent
+}
+
+// WorkerLimit returns the max number of the workers.
+// It is Workers if specified, otherwise the peak of MaxConcurrent and TargetConcurrent of Stages.
+func (s *Setting) WorkerLimit() int {
+	if s.Workers > 0 {
+		return s.Workers
+	}
+	if s.MaxConcurrent == 0 {
+		return defaultMaxWorkers
+	}
+	peak := s.MaxConcurrent
+	for _, st := range s.Stages {
+		if st.TargetConcurrent == 0 {
+			return defaultMaxWorkers
+		}
+		peak = max(peak, st.TargetConcurrent)
+	}
+	return peak
+}
+
+// BacklogSize returns MaxBacklog, or the default if it is not specified.
+func (s *Setting) BacklogSize() int {
+	if s.MaxBacklog > 0 {
+		return s.MaxBacklog
+	}
+	return defaultMaxBacklog
+}
+
+// Iterative reports whether the measurement ends by the number of the requests instead of the duration.
+func (s *Setting) Iterative() bool {
+	return s.Iterations > 0 || s.IterationsPerVU > 0
+}
+
+// MeasureDuration returns how long the measurement lasts, it is the total duration of Stages if they are specified.
+// When the measurement is Iterative, it returns MaxDuration, and 0 means no limit.
+func (s *Setting) MeasureDuration() time.Duration {
+	if s.Iterative() {
+		return s.MaxDuration
+	}
+	if len(s.Stages) == 0 {
+		return s.RunDuration
+	}
+
+	var d time.Duration
+	for _, st := range s.Stages {
+		d += st.Duration
+	}
+	return d
+}
+
+// TargetRPS returns the average max RPS over the measurement weighted by the duration of Stages, or MaxRPS if they are not specified.
+// It returns 0 when the RPS is unlimited at any time of the measurement.
+func (s *Setting) TargetRPS() float64 {
+	if len(s.Stages) == 0 {
+		return float64(s.MaxRPS)
+	}
+
+	var requests float64
+	prev := s.MaxRPS
+	for _, st := range s.Stages {
+		rps := float64(st.TargetRPS)
+		if s.StageMode == StageModeLinear {
+			// A linear ramp from or to unlimited stays at the previous target until the end of the stage.
+			rps = float64(prev)
+			if prev != 0 && st.TargetRPS != 0 {
+				rps = float64(prev+st.TargetRPS) / 2
+			}
+		}
+		if rps == 0 {
+			return 0
+		}
+		requests += rps * st.Duration.Seconds()
+		prev = st.TargetRPS
+	}
+	return requests / s.MeasureDuration().Seconds()
+}
+
+// StageAt returns the index of the stage and its max RPS and max concurrent at elapsed from the beginning of the measurement.
+// When Stages is not specified or elapsed exceeds them, it returns the last values.
+func (s *Setting) StageAt(elapsed time.Duration) (idx, maxRPS, maxConcurrent int) {
+	if len(s.Stages) == 0 {
+		return 0, s.MaxRPS, s.MaxConcurrent
+	}
+
+	prevRPS, prevConcurrent := s.MaxRPS, s.MaxConcurrent
+	for i, st := range s.Stages {
+		if elapsed >= st.Duration && i != len(s.Stages)-1 {
+			elapsed -= st.Duration
+			prevRPS, prevConcurrent = st.TargetRPS, st.TargetConcurrent
+			continue
+		}
+		if s.StageMode == StageModeStep || elapsed >= st.Duration {
+			return i, st.TargetRPS, st.TargetConcurrent
+		}
+		progress := float64(elapsed) / float64(st.Duration)
+		return i, interpolate(prevRPS, st.TargetRPS, progress), interpolate(prevConcurrent, st.TargetConcurrent, progress)
+	}
+
+	// Unreachable because the last stage always returns.
+	last := s.Stages[len(s.Stages)-1]
+	return len(s.Stages) - 1, last.TargetRPS, last.TargetConcurrent
+}
+
+func interpolate(from, to int, progress float64) int {
+	if from == 0 || to == 0 {
+		return from
+	}
+	v := int(float64(from) + float64(to-from)*progress)
+	if v < 1 {
+		return 1
+	}
+	return v
+}
+
+// ValidateStages checks the given stages are runnable.
+func ValidateStages(stages []Stage) error {
+	for _, st := range stages {
+		if !(st.Duration > 0*time.Second) {
+			return errors.New("stage duration must be > 0 sec")
+		}
+		if !(st.TargetRPS >= 0) {
+			return errors.New("stage target RPS must be >= 0")
+		}
+		if !(st.TargetConcurrent >= 0) {
+			return errors.New("stage target concurrent must be >= 0")
+		}
+	}
+	return nil
 }
 
 // New returns Setting instance made by user defined config.
@@ -39,33 +328,53 @@ func New(maxConcurrent, maxRPS int, runDuration, warmUpTime time.Duration) (*Set
 	return newSetting(maxConcurrent, maxRPS, runDuration, warmUpTime)
 }
 
+// NewIterations returns Setting instance which measures the given number of requests instead of the duration.
+// maxDuration caps the measurement, and 0 means no limit.
+func NewIterations(maxConcurrent, maxRPS, iterations int, maxDuration, warmUpTime time.Duration) (*Setting, error) {
+	return newIterationSetting(maxConcurrent, maxRPS, iterations, maxDuration, warmUpTime)
+}
+
 // FromDefaultConfig returns Setting by flag or default value config.
+// When -n is specified, the requests are measured by the count, and -d caps the duration only if it is specified explicitly.
 func FromDefaultFlag() (*Setting, error) {
 	c := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
-	maxConcurrent := c.Int("p", defaultMaxConcurrent, "Specify the number of parallels executions. 0 means unlimited (default: 1, it's not concurrently)")
-	runDuration := c.Duration("d", defaultRunDuration, "Running duration, ex: 300s or 5m etc... (default: 5s)")
-	warmUpTime := c.Duration("w", defaultWarmUpTime, "Exclude from results for a given time after startup, ex: 300s or 5m etc... (default: 5s)")
-	maxRPS := c.Int("r", defaultMaxRPS, "Specify the max request per second. 0 means unlimited (default: 1)")
+	fromFlags := DefineFlags(c)
 	if err := c.Parse(os.Args[1:]); err != nil {
 		return nil, err
 	}
+	return fromFlags()
+}
 
-	return newSetting(*maxConcurrent, *maxRPS, *runDuration, *warmUpTime)
+// DefineFlags defines the flags of FromDefaultFlag (-p, -d, -w, -r and -n) in fs, so that they can be parsed together with other flags.
+// The returned function makes Setting from them after fs is parsed.
+func DefineFlags(fs *flag.FlagSet) func() (*Setting, error) {
+	maxConcurrent := fs.Int("p", defaultMaxConcurrent, "Specify the number of parallels executions. 0 means unlimited (default: 1, it's not concurrently)")
+	runDuration := fs.Duration("d", defaultRunDuration, "Running duration, ex: 300s or 5m etc... (default: 5s)")
+	warmUpTime := fs.Duration("w", defaultWarmUpTime, "Exclude from results for a given time after startup, ex: 300s or 5m etc... (default: 5s)")
+	maxRPS := fs.Int("r", defaultMaxRPS, "Specify the max request per second. 0 means unlimited (default: 1)")
+	iterations := fs.Int("n", 0, "Specify the number of requests to measure instead of -d, which then caps the duration if specified (default: 0, use -d)")
+
+	return func() (*Setting, error) {
+		if *iterations == 0 {
+			return newSetting(*maxConcurrent, *maxRPS, *runDuration, *warmUpTime)
+		}
+		var maxDuration time.Duration
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "d" {
+				maxDuration = *runDuration
+			}
+		})
+		return newIterationSetting(*maxConcurrent, *maxRPS, *iterations, maxDuration, *warmUpTime)
+	}
 }
 
 func newSetting(maxConcurrent, maxRPS int, runDuration, warmUpTime time.Duration) (*Setting, error) {
-	if !(maxConcurrent >= 0) {
-		return nil, errors.New("max concurrent must be >= 0")
-	}
-	if !(maxRPS >= 0) {
-		return nil, errors.New("max RPS must be >= 0")
+	if err := validateLimits(maxConcurrent, maxRPS, warmUpTime); err != nil {
+		return nil, err
 	}
 	if !(runDuration > 0*time.Second) {
 		return nil, errors.New("run duration must be > 0 sec")
 	}
-	if !(warmUpTime >= 0*time.Second) {
-		return nil, errors.New("warm up time must be >= 0 sec")
-	}
 
 	return &Setting{
 		MaxConcurrent: maxConcurrent,
@@ -74,3 +383,36 @@ func newSetting(maxConcurrent, maxRPS int, runDuration, warmUpTime time.Duration
 		MaxRPS:        maxRPS,
 	}, nil
 }
+
+func newIterationSetting(maxConcurrent, maxRPS, iterations int, maxDuration, warmUpTime time.Duration) (*Setting, error) {
+	if err := validateLimits(maxConcurrent, maxRPS, warmUpTime); err != nil {
+		return nil, err
+	}
+	if !(iterations > 0) {
+		return nil, errors.New("iterations must be > 0")
+	}
+	if !(maxDuration >= 0*time.Second) {
+		return nil, errors.New("max duration must be >= 0 sec")
+	}
+
+	return &Setting{
+		MaxConcurrent: maxConcurrent,
+		Iterations:    iterations,
+		MaxDuration:   maxDuration,
+		WarmUpTime:    warmUpTime,
+		MaxRPS:        maxRPS,
+	}, nil
+}
+
+func validateLimits(maxConcurrent, maxRPS int, warmUpTime time.Duration) error {
+	if !(maxConcurrent >= 0) {
+		return errors.New("max concurrent must be >= 0")
+	}
+	if !(maxRPS >= 0) {
+		return errors.New("max RPS must be >= 0")
+	}
+	if !(warmUpTime >= 0*time.Second) {
+		return errors.New("warm up time must be >= 0 sec")
+	}
+	return nil
+}
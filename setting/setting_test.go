@@ -1,12 +1,14 @@
 package setting
 
 import (
+	"flag"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFromDefault(t *testing.T) {
@@ -37,6 +39,31 @@ func TestFromDefault(t *testing.T) {
 				MaxRPS:        2,
 			},
 		},
+		"iterations": {
+			args:      []string{"test", "-n", "100"},
+			wantError: assert.NoError,
+			wantSetting: &Setting{
+				MaxConcurrent: 1,
+				Iterations:    100,
+				WarmUpTime:    5 * time.Second,
+				MaxRPS:        1,
+			},
+		},
+		"iterations with max duration": {
+			args:      []string{"test", "-n", "100", "-d", "10s"},
+			wantError: assert.NoError,
+			wantSetting: &Setting{
+				MaxConcurrent: 1,
+				Iterations:    100,
+				MaxDuration:   10 * time.Second,
+				WarmUpTime:    5 * time.Second,
+				MaxRPS:        1,
+			},
+		},
+		"ng: invalid n": {
+			args:      []string{"test", "-n", "-1"},
+			wantError: assert.Error,
+		},
 		"ng: no unit": {
 			args:      []string{"test", "-p", "2", "-d", "2", "-w", "2"},
 			wantError: assert.Error,
@@ -73,6 +100,20 @@ func TestFromDefault(t *testing.T) {
 	}
 }
 
+func TestDefineFlags(t *testing.T) {
+	t.Parallel()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fromFlags := DefineFlags(fs)
+	url := fs.String("url", "", "")
+	require.NoError(t, fs.Parse([]string{"-p", "2", "-url", "http://localhost", "-n", "100", "-d", "1m"}))
+
+	s, err := fromFlags()
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost", *url)
+	assert.Empty(t, cmp.Diff(&Setting{MaxConcurrent: 2, Iterations: 100, MaxDuration: time.Minute, WarmUpTime: 5 * time.Second, MaxRPS: 1}, s))
+}
+
 func TestNewSetting(t *testing.T) {
 	t.Parallel()
 
@@ -156,3 +197,275 @@ func TestNewSetting(t *testing.T) {
 		})
 	}
 }
+
+func TestNewIterations(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		iterations  int
+		maxDuration time.Duration
+		wantError   assert.ErrorAssertionFunc
+		wantSetting *Setting
+	}{
+		"ok": {
+			iterations:  100,
+			maxDuration: 0,
+			wantError:   assert.NoError,
+			wantSetting: &Setting{MaxConcurrent: 1, MaxRPS: 1, Iterations: 100, WarmUpTime: 5 * time.Second},
+		},
+		"ok: max duration": {
+			iterations:  100,
+			maxDuration: 10 * time.Second,
+			wantError:   assert.NoError,
+			wantSetting: &Setting{MaxConcurrent: 1, MaxRPS: 1, Iterations: 100, MaxDuration: 10 * time.Second, WarmUpTime: 5 * time.Second},
+		},
+		"ng: iterations": {
+			iterations: 0,
+			wantError:  assert.Error,
+		},
+		"ng: max duration": {
+			iterations:  100,
+			maxDuration: -1 * time.Second,
+			wantError:   assert.Error,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := NewIterations(defaultMaxConcurrent, defaultMaxRPS, tc.iterations, tc.maxDuration, defaultWarmUpTime)
+			tc.wantError(t, err)
+			diff := cmp.Diff(tc.wantSetting, actual)
+			assert.Empty(t, diff)
+		})
+	}
+}
+
+func TestStageAt(t *testing.T) {
+	t.Parallel()
+
+	stages := []Stage{
+		{Duration: 10 * time.Second, TargetRPS: 100, TargetConcurrent: 10},
+		{Duration: 20 * time.Second, TargetRPS: 100, TargetConcurrent: 10},
+		{Duration: 10 * time.Second, TargetRPS: 0, TargetConcurrent: 0},
+	}
+	testCases := map[string]struct {
+		setting           *Setting
+		elapsed           time.Duration
+		wantIdx           int
+		wantMaxRPS        int
+		wantMaxConcurrent int
+	}{
+		"no stages": {
+			setting:           &Setting{MaxRPS: 5, MaxConcurrent: 2},
+			elapsed:           3 * time.Second,
+			wantIdx:           0,
+			wantMaxRPS:        5,
+			wantMaxConcurrent: 2,
+		},
+		"step: first stage": {
+			setting:           &Setting{MaxRPS: 10, MaxConcurrent: 1, Stages: stages, StageMode: StageModeStep},
+			elapsed:           0,
+			wantIdx:           0,
+			wantMaxRPS:        100,
+			wantMaxConcurrent: 10,
+		},
+		"linear: beginning of ramp": {
+			setting:           &Setting{MaxRPS: 10, MaxConcurrent: 1, Stages: stages, StageMode: StageModeLinear},
+			elapsed:           0,
+			wantIdx:           0,
+			wantMaxRPS:        10,
+			wantMaxConcurrent: 1,
+		},
+		"linear: middle of ramp": {
+			setting:           &Setting{MaxRPS: 10, MaxConcurrent: 1, Stages: stages, StageMode: StageModeLinear},
+			elapsed:           5 * time.Second,
+			wantIdx:           0,
+			wantMaxRPS:        55,
+			wantMaxConcurrent: 5,
+		},
+		"linear: plateau": {
+			setting:           &Setting{MaxRPS: 10, MaxConcurrent: 1, Stages: stages, StageMode: StageModeLinear},
+			elapsed:           15 * time.Second,
+			wantIdx:           1,
+			wantMaxRPS:        100,
+			wantMaxConcurrent: 10,
+		},
+		"linear: ramp to unlimited keeps previous": {
+			setting:           &Setting{MaxRPS: 10, MaxConcurrent: 1, Stages: stages, StageMode: StageModeLinear},
+			elapsed:           35 * time.Second,
+			wantIdx:           2,
+			wantMaxRPS:        100,
+			wantMaxConcurrent: 10,
+		},
+		"exceeded": {
+			setting:           &Setting{MaxRPS: 10, MaxConcurrent: 1, Stages: stages, StageMode: StageModeLinear},
+			elapsed:           60 * time.Second,
+			wantIdx:           2,
+			wantMaxRPS:        0,
+			wantMaxConcurrent: 0,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			idx, maxRPS, maxConcurrent := tc.setting.StageAt(tc.elapsed)
+			assert.Equal(t, tc.wantIdx, idx)
+			assert.Equal(t, tc.wantMaxRPS, maxRPS)
+			assert.Equal(t, tc.wantMaxConcurrent, maxConcurrent)
+		})
+	}
+}
+
+func TestMeasureDuration(t *testing.T) {
+	t.Parallel()
+
+	s := &Setting{RunDuration: 5 * time.Second}
+	assert.Equal(t, 5*time.Second, s.MeasureDuration())
+
+	s.Stages = []Stage{{Duration: 2 * time.Second}, {Duration: 3 * time.Second}, {Duration: 4 * time.Second}}
+	assert.Equal(t, 9*time.Second, s.MeasureDuration())
+
+	s = &Setting{RunDuration: 5 * time.Second, Iterations: 100, MaxDuration: 10 * time.Second}
+	assert.Equal(t, 10*time.Second, s.MeasureDuration())
+}
+
+func TestTargetRPS(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		setting *Setting
+		want    float64
+	}{
+		"max RPS":   {setting: &Setting{MaxRPS: 10}, want: 10},
+		"unlimited": {setting: &Setting{MaxRPS: 0}, want: 0},
+		"step": {
+			setting: &Setting{MaxRPS: 10, Stages: []Stage{{Duration: 1 * time.Second, TargetRPS: 10}, {Duration: 3 * time.Second, TargetRPS: 30}}},
+			want:    25,
+		},
+		"linear": {
+			setting: &Setting{MaxRPS: 10, StageMode: StageModeLinear, Stages: []Stage{{Duration: 2 * time.Second, TargetRPS: 30}, {Duration: 2 * time.Second, TargetRPS: 30}}},
+			want:    25,
+		},
+		"unlimited stage": {
+			setting: &Setting{MaxRPS: 10, Stages: []Stage{{Duration: 1 * time.Second, TargetRPS: 10}, {Duration: 1 * time.Second, TargetRPS: 0}}},
+			want:    0,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+			assert.InDelta(t, tc.want, tc.setting.TargetRPS(), 1e-9)
+		})
+	}
+}
+
+func TestValidateStages(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		stages    []Stage
+		wantError assert.ErrorAssertionFunc
+	}{
+		"ok: empty": {
+			stages:    nil,
+			wantError: assert.NoError,
+		},
+		"ok": {
+			stages:    []Stage{{Duration: 1 * time.Second, TargetRPS: 0, TargetConcurrent: 0}},
+			wantError: assert.NoError,
+		},
+		"ng: duration": {
+			stages:    []Stage{{Duration: 0}},
+			wantError: assert.Error,
+		},
+		"ng: target rps": {
+			stages:    []Stage{{Duration: 1 * time.Second, TargetRPS: -1}},
+			wantError: assert.Error,
+		},
+		"ng: target concurrent": {
+			stages:    []Stage{{Duration: 1 * time.Second, TargetConcurrent: -1}},
+			wantError: assert.Error,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+			tc.wantError(t, ValidateStages(tc.stages))
+		})
+	}
+}
+
+func TestValidateStability(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		stability *Stability
+		wantError assert.ErrorAssertionFunc
+	}{
+		"ok: nil": {
+			stability: nil,
+			wantError: assert.NoError,
+		},
+		"ok": {
+			stability: &Stability{Tolerance: 0.1},
+			wantError: assert.NoError,
+		},
+		"ng: window": {
+			stability: &Stability{Window: -1, Tolerance: 0.1},
+			wantError: assert.Error,
+		},
+		"ng: tolerance": {
+			stability: &Stability{Tolerance: 0},
+			wantError: assert.Error,
+		},
+		"ng: percentiles": {
+			stability: &Stability{Tolerance: 0.1, Percentiles: []int{50, 101}},
+			wantError: assert.Error,
+		},
+		"ng: max time": {
+			stability: &Stability{Tolerance: 0.1, MaxTime: -1 * time.Second},
+			wantError: assert.Error,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+			tc.wantError(t, ValidateStability(tc.stability))
+		})
+	}
+}
+
+func TestWorkerLimit(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		setting *Setting
+		want    int
+	}{
+		"fixed":                  {setting: &Setting{Workers: 3, MaxConcurrent: 10}, want: 3},
+		"max concurrent":         {setting: &Setting{MaxConcurrent: 10}, want: 10},
+		"unlimited":              {setting: &Setting{}, want: defaultMaxWorkers},
+		"peak of stages":         {setting: &Setting{MaxConcurrent: 10, Stages: []Stage{{TargetConcurrent: 20}, {TargetConcurrent: 5}}}, want: 20},
+		"unlimited in the stage": {setting: &Setting{MaxConcurrent: 10, Stages: []Stage{{TargetConcurrent: 20}, {TargetConcurrent: 0}}}, want: defaultMaxWorkers},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, tc.setting.WorkerLimit())
+		})
+	}
+}
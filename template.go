@@ -2,9 +2,13 @@ package otchkiss
 
 const defaultReportTemplate = `
 [Setting]
-* warm up time:   {{.WarmUpTime}}
-* duration:       {{.Duration}}
-* max concurrent: {{.MaxConcurrent}}
+{{if .WarmUpRequests}}* warm up:        {{.WarmUpRequests}} requests
+{{else if .WarmUpStability}}* warm up:        until {{.WarmUpStability}}
+{{else}}* warm up time:   {{.WarmUpTime}}
+{{end}}* duration:       {{.Duration}}
+{{if .CoolDownTime}}* cool down time: {{.CoolDownTime}}
+{{end}}{{if .Iterations}}* iterations:     {{.Iterations}}
+{{end}}* max concurrent: {{.MaxConcurrent}}
 * max RPS:        {{.MaxRPS}}
 
 [Request]
@@ -13,7 +17,17 @@ const defaultReportTemplate = `
 * failed:     {{.Failed}}
 * error rate: {{.ErrorRate}} %
 * RPS:        {{.RPS}}
-
+{{if .TargetRPS}}* target RPS: {{.TargetRPS}} (achieved: {{.AchievedRPS}} %)
+{{end}}{{if .Dropped}}* dropped:    {{.Dropped}}
+{{end}}{{if .Late}}* late:       {{.Late}}
+{{end}}{{if .Thresholds}}
+[Thresholds: {{.Verdict}}]
+{{range .Thresholds}}* {{.Result}}: {{.Threshold}} (observed: {{.Observed}})
+{{end}}{{end}}{{if .Errors}}
+[Errors]
+{{range .Errors}}* {{.Key}}: {{.Count}} ({{.Rate}} %)
+{{end}}{{if .OtherErrorKinds}}* and {{.OtherErrorKinds}} other kinds
+{{end}}{{end}}
 [Latency]
 * max: {{.MaxLatency}} ms
 * min: {{.MinLatency}} ms
@@ -21,7 +35,40 @@ const defaultReportTemplate = `
 * med: {{.MedLatency}} ms
 * 99th percentile: {{.Latency99p}} ms
 * 90th percentile: {{.Latency90p}} ms
-
+{{with .CorrectedLatency}}
+[Latency (corrected)]
+* max: {{.MaxLatency}} ms
+* min: {{.MinLatency}} ms
+* avg: {{.AvgLatency}} ms
+* med: {{.MedLatency}} ms
+* 99th percentile: {{.Latency99p}} ms
+* 90th percentile: {{.Latency90p}} ms
+{{end}}{{if .Timings}}
+[Timings (ms)]
+{{printf "%-10s %10s %10s %10s %10s %10s %10s" "" "count" "min" "med" "90th" "99th" "max"}}
+{{range .Timings}}{{printf "%-10s %10s %10s %10s %10s %10s %10s" .Name .Count .MinLatency .MedLatency .Latency90p .Latency99p .MaxLatency}}
+{{end}}{{end}}{{if .Stages}}
+[Stages]
+{{range .Stages}}* stage {{.Index}}: {{.Duration}} (target RPS: {{.TargetRPS}}, target concurrent: {{.TargetConcurrent}})
+  * total: {{.TotalRequests}}, failed: {{.Failed}}, error rate: {{.ErrorRate}} %, RPS: {{.RPS}}
+  * med: {{.MedLatency}} ms, 99th percentile: {{.Latency99p}} ms
+{{end}}{{end}}{{range .Scenarios}}
+[Scenario: {{.Name}}]
+* weight: {{.Weight}}, share: {{.Share}} %
+* total: {{.TotalRequests}}, failed: {{.Failed}}, error rate: {{.ErrorRate}} %, RPS: {{.RPS}}
+* max: {{.MaxLatency}} ms, min: {{.MinLatency}} ms, avg: {{.AvgLatency}} ms, med: {{.MedLatency}} ms
+* 99th percentile: {{.Latency99p}} ms, 90th percentile: {{.Latency90p}} ms
+{{end}}{{range .Tags}}
+[Tag: {{.Tag}}]
+* total: {{.TotalRequests}}, failed: {{.Failed}}, error rate: {{.ErrorRate}} %, RPS: {{.RPS}}
+* max: {{.MaxLatency}} ms, min: {{.MinLatency}} ms, avg: {{.AvgLatency}} ms, med: {{.MedLatency}} ms
+* 99th percentile: {{.Latency99p}} ms, 90th percentile: {{.Latency90p}} ms
+{{end}}
 [Histogram]
-{{.Histogram}}
+{{.Histogram}}{{if .RPSChart}}
+[Time Series]
+RPS:
+{{.RPSChart}}
+99th percentile (ms):
+{{.Latency99pChart}}{{end}}
 `
@@ -0,0 +1,258 @@
+package threshold
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+)
+
+// Metric is the statistic of the Result which a Threshold checks.
+type Metric string
+
+const (
+	// ErrorRate is the percentage of the failed requests.
+	ErrorRate Metric = "error_rate"
+
+	// RPS is the number of the requests per second.
+	RPS Metric = "rps"
+
+	// MeanLatency, MaxLatency and MinLatency are the latencies in seconds.
+	MeanLatency Metric = "avg"
+	MaxLatency  Metric = "max"
+	MinLatency  Metric = "min"
+)
+
+// Percentile returns the Metric of the p-th percentile latency in seconds, such as "p99".
+func Percentile(p int) Metric {
+	return Metric(fmt.Sprintf("p%d", p))
+}
+
+// percentile returns p of the percentile metric, ok is false when m is not a percentile.
+func (m Metric) percentile() (p int, ok bool) {
+	s, found := strings.CutPrefix(string(m), "p")
+	if !found {
+		return 0, false
+	}
+	p, err := strconv.Atoi(s)
+	if err != nil || p < 0 || p > 100 {
+		return 0, false
+	}
+	return p, true
+}
+
+func (m Metric) latency() bool {
+	_, ok := m.percentile()
+	return ok || m == MeanLatency || m == MaxLatency || m == MinLatency
+}
+
+func (m Metric) validate() error {
+	if m.latency() || m == ErrorRate || m == RPS {
+		return nil
+	}
+	return fmt.Errorf("unknown metric: %q", m)
+}
+
+// Format returns v in the unit of the metric, such as "250ms" for the latencies and "0.5%" for the error rate.
+func (m Metric) Format(v float64) string {
+	switch {
+	case m.latency():
+		return time.Duration(v * float64(time.Second)).String()
+	case m == ErrorRate:
+		return strconv.FormatFloat(v, 'f', -1, 64) + "%"
+	default:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+}
+
+// Op is the comparison of the observed value with the limit of a Threshold.
+type Op string
+
+const (
+	Less           Op = "<"
+	LessOrEqual    Op = "<="
+	Greater        Op = ">"
+	GreaterOrEqual Op = ">="
+)
+
+func (op Op) compare(observed, limit float64) (bool, error) {
+	switch op {
+	case Less:
+		return observed < limit, nil
+	case LessOrEqual:
+		return observed <= limit, nil
+	case Greater:
+		return observed > limit, nil
+	case GreaterOrEqual:
+		return observed >= limit, nil
+	default:
+		return false, fmt.Errorf("unknown operator: %q", op)
+	}
+}
+
+// Threshold is a condition which the Result must satisfy, such as "p99 < 250ms".
+type Threshold struct {
+	Metric Metric
+	Op     Op
+
+	// Value is the limit in the unit of Metric, that is seconds for the latencies, percent for ErrorRate and requests per second for RPS.
+	Value float64
+
+	// Tag restricts the condition to the requests with the tag.
+	// nil means all the requests.
+	Tag *result.Tag
+}
+
+// String returns the threshold in the form which Parse accepts.
+func (th Threshold) String() string {
+	m := string(th.Metric)
+	if th.Tag != nil {
+		m += "{" + th.Tag.String() + "}"
+	}
+	return fmt.Sprintf("%s %s %s", m, th.Op, th.Metric.Format(th.Value))
+}
+
+// Parse returns the Threshold written as "<metric>[{<key>=<value>}] <op> <value>".
+// The metric is one of pNN (such as p99), avg, max, min, error_rate and rps,
+// the op is one of <, <=, > and >=, and the value of the latencies is a duration such as 250ms.
+//
+//	p99 < 250ms
+//	error_rate < 0.5%
+//	rps >= 900
+//	p95{endpoint=search} <= 1s
+func Parse(s string) (Threshold, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 {
+		return Threshold{}, fmt.Errorf("invalid threshold %q: must be <metric> <op> <value>", s)
+	}
+
+	var th Threshold
+	metric := fields[0]
+	if i := strings.Index(metric, "{"); i >= 0 {
+		if !strings.HasSuffix(metric, "}") {
+			return Threshold{}, fmt.Errorf("invalid threshold %q: unclosed tag", s)
+		}
+		key, value, ok := strings.Cut(metric[i+1:len(metric)-1], "=")
+		if !ok || key == "" {
+			return Threshold{}, fmt.Errorf("invalid threshold %q: tag must be <key>=<value>", s)
+		}
+		th.Tag = &result.Tag{Key: key, Value: value}
+		metric = metric[:i]
+	}
+	th.Metric = Metric(metric)
+	th.Op = Op(fields[1])
+
+	v, err := th.Metric.parse(fields[2])
+	if err != nil {
+		return Threshold{}, fmt.Errorf("invalid threshold %q: %w", s, err)
+	}
+	th.Value = v
+
+	if err := th.Validate(); err != nil {
+		return Threshold{}, fmt.Errorf("invalid threshold %q: %w", s, err)
+	}
+	return th, nil
+}
+
+func (m Metric) parse(s string) (float64, error) {
+	if m.latency() {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+		return d.Seconds(), nil
+	}
+	if m == ErrorRate {
+		s = strings.TrimSuffix(s, "%")
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// Validate checks the threshold is evaluable.
+func (th Threshold) Validate() error {
+	if err := th.Metric.validate(); err != nil {
+		return err
+	}
+	if _, err := th.Op.compare(0, 0); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Outcome is the result of a Threshold.
+type Outcome struct {
+	Threshold Threshold
+
+	// Observed is the value of the metric in the same unit as Threshold.Value.
+	Observed float64
+	Passed   bool
+
+	// Err is why the metric could not be observed, such as no request with the tag.
+	// The threshold does not pass when it is not nil.
+	Err error
+}
+
+// Verdict is the results of the thresholds.
+type Verdict struct {
+	// Passed reports whether all the thresholds passed.
+	Passed   bool
+	Outcomes []Outcome
+}
+
+// Evaluate checks the thresholds against r.
+// elapsed is the duration to compute RPS, it is usually Result.Elapsed.
+func Evaluate(r *result.Result, elapsed time.Duration, thresholds []Threshold) Verdict {
+	v := Verdict{
+		Passed:   true,
+		Outcomes: make([]Outcome, 0, len(thresholds)),
+	}
+	for _, th := range thresholds {
+		o := Outcome{Threshold: th}
+		o.Observed, o.Err = observe(r, elapsed, th)
+		if o.Err == nil {
+			o.Passed, o.Err = th.Op.compare(o.Observed, th.Value)
+		}
+		v.Passed = v.Passed && o.Passed
+		v.Outcomes = append(v.Outcomes, o)
+	}
+	return v
+}
+
+func observe(r *result.Result, elapsed time.Duration, th Threshold) (float64, error) {
+	if err := th.Metric.validate(); err != nil {
+		return 0, err
+	}
+	if th.Tag != nil {
+		// Tagged creates the Result of the tag, so look it up first not to add an empty tag to r.
+		if !slices.Contains(r.Tags(), *th.Tag) {
+			return 0, fmt.Errorf("no request with the tag %s", th.Tag)
+		}
+		r = r.Tagged(*th.Tag)
+	}
+	total := r.Succeeded() + r.Failed()
+
+	switch th.Metric {
+	case ErrorRate:
+		if total == 0 {
+			return 0, errors.New("no result data")
+		}
+		return float64(r.Failed()) / float64(total) * 100, nil
+	case RPS:
+		if elapsed <= 0 {
+			return 0, errors.New("no elapsed time")
+		}
+		return float64(total) / elapsed.Seconds(), nil
+	case MeanLatency:
+		return r.MeanLatency()
+	case MaxLatency:
+		return r.PercentileLatency(100)
+	case MinLatency:
+		return r.PercentileLatency(0)
+	}
+	p, _ := th.Metric.percentile()
+	return r.PercentileLatency(p)
+}
@@ -0,0 +1,100 @@
+package threshold
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		in        string
+		want      Threshold
+		wantError assert.ErrorAssertionFunc
+	}{
+		"percentile": {
+			in:        "p99 < 250ms",
+			want:      Threshold{Metric: Percentile(99), Op: Less, Value: 0.25},
+			wantError: assert.NoError,
+		},
+		"error rate": {
+			in:        "error_rate <= 0.5%",
+			want:      Threshold{Metric: ErrorRate, Op: LessOrEqual, Value: 0.5},
+			wantError: assert.NoError,
+		},
+		"rps": {
+			in:        "rps >= 900",
+			want:      Threshold{Metric: RPS, Op: GreaterOrEqual, Value: 900},
+			wantError: assert.NoError,
+		},
+		"tag": {
+			in:        "avg{endpoint=search} > 1s",
+			want:      Threshold{Metric: MeanLatency, Op: Greater, Value: 1, Tag: &result.Tag{Key: "endpoint", Value: "search"}},
+			wantError: assert.NoError,
+		},
+		"ng: fields":       {in: "p99<250ms", wantError: assert.Error},
+		"ng: metric":       {in: "p101 < 250ms", wantError: assert.Error},
+		"ng: op":           {in: "p99 == 250ms", wantError: assert.Error},
+		"ng: latency unit": {in: "p99 < 250", wantError: assert.Error},
+		"ng: tag":          {in: "p99{endpoint} < 250ms", wantError: assert.Error},
+		"ng: unclosed tag": {in: "p99{endpoint=search < 250ms", wantError: assert.Error},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := Parse(tc.in)
+			tc.wantError(t, err)
+			if err == nil {
+				assert.Equal(t, tc.want, actual)
+				assert.Equal(t, tc.in, actual.String())
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	t.Parallel()
+
+	r, err := result.WithCapacity(4)
+	require.NoError(t, err)
+	r.AppendSuccess(0.1)
+	r.AppendSuccess(0.2)
+	r.AppendSuccess(0.3)
+	r.AppendFail(0.4, errors.New("err"))
+	search := result.Tag{Key: "endpoint", Value: "search"}
+	r.Tagged(search).AppendSuccess(0.5)
+
+	thresholds := []Threshold{
+		{Metric: MaxLatency, Op: Less, Value: 0.5},
+		{Metric: ErrorRate, Op: Less, Value: 20},
+		{Metric: RPS, Op: GreaterOrEqual, Value: 2},
+		{Metric: Percentile(50), Op: LessOrEqual, Value: 0.5, Tag: &search},
+		{Metric: MinLatency, Op: Less, Value: 1, Tag: &result.Tag{Key: "endpoint", Value: "browse"}},
+	}
+	v := Evaluate(r, 2*time.Second, thresholds)
+
+	assert.False(t, v.Passed)
+	require.Len(t, v.Outcomes, 5)
+	assert.True(t, v.Outcomes[0].Passed)
+	assert.InDelta(t, 0.4, v.Outcomes[0].Observed, 1e-9)
+	assert.False(t, v.Outcomes[1].Passed)
+	assert.InDelta(t, 25, v.Outcomes[1].Observed, 1e-9)
+	assert.True(t, v.Outcomes[2].Passed)
+	assert.InDelta(t, 2, v.Outcomes[2].Observed, 1e-9)
+	assert.True(t, v.Outcomes[3].Passed)
+	assert.False(t, v.Outcomes[4].Passed)
+	assert.Error(t, v.Outcomes[4].Err)
+	assert.Len(t, r.Tags(), 1, "evaluation must not add the tag")
+
+	assert.True(t, Evaluate(r, 2*time.Second, thresholds[:1]).Passed)
+	assert.True(t, Evaluate(r, 2*time.Second, nil).Passed)
+}
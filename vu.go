@@ -0,0 +1,143 @@
+package otchkiss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/result"
+	"github.com/ryo-yamaoka/otchkiss/setting"
+)
+
+// RequesterFactory returns the Requester of the virtual user vu, which is numbered from 0.
+// Each virtual user has its own Requester, so per user state such as cookies, tokens and connections needs no lock.
+type RequesterFactory func(vu int) (Requester, error)
+
+// NewVirtualUsers returns Otchkiss instance which runs the virtual users made by factory with default setting.
+// The number of virtual users is -p, and the command line arguments are parsed in the same way as New.
+func NewVirtualUsers(factory RequesterFactory) (*Otchkiss, error) {
+	s, err := setting.FromDefaultFlag()
+	if err != nil {
+		return nil, err
+	}
+	r, err := result.New()
+	if err != nil {
+		return nil, err
+	}
+
+	return newVirtualUsers(factory, s, r)
+}
+
+// FromVirtualUsers returns Otchkiss instance which runs the virtual users made by factory by user specified setting.
+// resultCapacity is the same as FromConfig.
+func FromVirtualUsers(factory RequesterFactory, setting *setting.Setting, resultCapacity int) (*Otchkiss, error) {
+	r, err := result.WithCapacity(resultCapacity)
+	if err != nil {
+		return nil, err
+	}
+	return newVirtualUsers(factory, setting, r)
+}
+
+func newVirtualUsers(factory RequesterFactory, setting *setting.Setting, r *result.Result) (*Otchkiss, error) {
+	if factory == nil {
+		return nil, errors.New("nil factory")
+	}
+	if setting == nil {
+		return nil, errors.New("nil setting")
+	}
+
+	ot := &Otchkiss{
+		Factory: factory,
+		Setting: setting,
+		Result:  r,
+	}
+	if err := ot.validateVirtualUsers(); err != nil {
+		return nil, err
+	}
+	return ot, nil
+}
+
+func (ot *Otchkiss) validateVirtualUsers() error {
+	if len(ot.Scenarios) != 0 {
+		return errors.New("virtual users cannot run with scenarios")
+	}
+	if ot.Setting.OpenModel {
+		return errors.New("virtual users cannot run in the open model")
+	}
+	if ot.Setting.VirtualUserCount() == 0 {
+		return errors.New("virtual users require virtual users or max concurrent > 0")
+	}
+	return nil
+}
+
+// makeVirtualUsers makes the Requester of each virtual user.
+// Requesters made by the factory are not initialized yet, so nothing is terminated on failure.
+func (ot *Otchkiss) makeVirtualUsers() ([]Requester, error) {
+	n := ot.Setting.VirtualUserCount()
+	requesters := make([]Requester, 0, n)
+	for vu := 0; vu < n; vu++ {
+		r, err := ot.Factory(vu)
+		if err != nil {
+			return nil, fmt.Errorf("virtual user %d: %w", vu, err)
+		}
+		if r == nil {
+			return nil, fmt.Errorf("virtual user %d: nil requester", vu)
+		}
+		requesters = append(requesters, r)
+	}
+	return requesters, nil
+}
+
+// runVirtualUsers starts a goroutine per virtual user, each of which calls its own RequestOne one after another.
+// The concurrency (including Stages) limits how many virtual users are active at the same time, and the arrival process paces them as a whole.
+func (ot *Otchkiss) runVirtualUsers(ctx context.Context, lc *loadControl, requesters []Requester, ph *phase, wg *sync.WaitGroup) {
+	for vu, r := range requesters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vctx := withVirtualUser(ctx, vu)
+			var iterations int
+			for {
+				if err := lc.sem.Acquire(ctx, 1); err != nil {
+					return
+				}
+				if !lc.wait(ctx) {
+					lc.sem.Release(1)
+					return
+				}
+				measured := ph.dispatch()
+				if measured {
+					iterations++
+					if ot.Setting.IterationsPerVU != 0 && iterations > ot.Setting.IterationsPerVU {
+						lc.sem.Release(1)
+						return
+					}
+					if !lc.takeIteration() {
+						if !ph.finishIterations() {
+							lc.sem.Release(1)
+							return
+						}
+						measured = false
+					}
+				}
+
+				stage, p := lc.currentStage(), ph.of(measured)
+				rctx, rec := withRecorder(vctx)
+				ot.requestBegun()
+				start := time.Now()
+				err := r.RequestOne(rctx)
+				elapsed := time.Since(start) // Do this before error handling to obtain the most accurate time possible.
+				lc.sem.Release(1)            // Do this before error handling to release semaphore as soon as possible.
+				ot.requestDone(p, measured, elapsed, err)
+
+				if measured {
+					ot.record(sample{stage: stage, dispatched: start, completed: start.Add(elapsed), elapsed: elapsed, tags: rec.recordedTags(), timings: rec.recordedTimings(), err: err})
+				} else {
+					ph.observe(elapsed)
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,152 @@
+package otchkiss
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ryo-yamaoka/otchkiss/setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// vuRequesterImpl counts its own requests without lock, which is safe only when each virtual user has its own instance.
+type vuRequesterImpl struct {
+	countingRequesterImpl
+	vu       int
+	requests int
+	wrongVU  bool
+}
+
+func (vr *vuRequesterImpl) RequestOne(ctx context.Context) error {
+	vr.requests++
+	if vu, ok := VirtualUser(ctx); !ok || vu != vr.vu {
+		vr.wrongVU = true
+	}
+	return nil
+}
+
+func TestFromVirtualUsers(t *testing.T) {
+	t.Parallel()
+
+	factory := func(int) (Requester, error) { return &testRequesterImpl{}, nil }
+	testCases := map[string]struct {
+		factory   RequesterFactory
+		setting   *setting.Setting
+		wantError assert.ErrorAssertionFunc
+	}{
+		"ok": {
+			factory:   factory,
+			setting:   &setting.Setting{MaxConcurrent: 2, RunDuration: 1 * time.Second},
+			wantError: assert.NoError,
+		},
+		"ok: virtual users without max concurrent": {
+			factory:   factory,
+			setting:   &setting.Setting{VirtualUsers: 2, RunDuration: 1 * time.Second},
+			wantError: assert.NoError,
+		},
+		"ng: nil factory": {
+			factory:   nil,
+			setting:   &setting.Setting{MaxConcurrent: 2, RunDuration: 1 * time.Second},
+			wantError: assert.Error,
+		},
+		"ng: no virtual user": {
+			factory:   factory,
+			setting:   &setting.Setting{RunDuration: 1 * time.Second},
+			wantError: assert.Error,
+		},
+		"ng: open model": {
+			factory:   factory,
+			setting:   &setting.Setting{MaxConcurrent: 2, MaxRPS: 10, RunDuration: 1 * time.Second, OpenModel: true},
+			wantError: assert.Error,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			ot, err := FromVirtualUsers(tc.factory, tc.setting, 0)
+			tc.wantError(t, err)
+			if err == nil {
+				assert.NotNil(t, ot.Factory)
+				assert.Nil(t, ot.Requester)
+			}
+		})
+	}
+}
+
+func TestStartVirtualUsers(t *testing.T) {
+	t.Parallel()
+
+	const n = 4
+	vus := make([]*vuRequesterImpl, 0, n)
+	ot, err := FromVirtualUsers(func(vu int) (Requester, error) {
+		r := &vuRequesterImpl{vu: vu}
+		vus = append(vus, r)
+		return r, nil
+	}, &setting.Setting{
+		MaxConcurrent: n,
+		RunDuration:   1 * time.Second,
+		Arrival:       mustReplay(t, 1000),
+	}, 1000)
+	require.NoError(t, err)
+	require.NoError(t, ot.Start(context.Background()))
+
+	require.Len(t, vus, n)
+	var total int
+	for i, vr := range vus {
+		assert.Equal(t, 1, vr.inits)
+		assert.Equal(t, 1, vr.terminates)
+		assert.False(t, vr.wrongVU, "virtual user %d must get its own number from the context", i)
+		total += vr.requests
+	}
+	assert.Equal(t, 1000, total)
+	assert.Equal(t, int64(1000), ot.Result.Succeeded())
+}
+
+func TestStartVirtualUsersError(t *testing.T) {
+	t.Parallel()
+
+	t.Run("factory", func(t *testing.T) {
+		t.Parallel()
+
+		var made []*countingRequesterImpl
+		ot, err := FromVirtualUsers(func(vu int) (Requester, error) {
+			if vu == 1 {
+				return nil, errors.New("factory")
+			}
+			r := &countingRequesterImpl{}
+			made = append(made, r)
+			return r, nil
+		}, &setting.Setting{MaxConcurrent: 2, RunDuration: 1 * time.Second}, 0)
+		require.NoError(t, err)
+
+		assert.Error(t, ot.Start(context.Background()))
+		require.Len(t, made, 1)
+		assert.Equal(t, 0, made[0].inits, "nothing must be initialized before every virtual user is made")
+	})
+
+	t.Run("init", func(t *testing.T) {
+		t.Parallel()
+
+		var made []*countingRequesterImpl
+		ot, err := FromVirtualUsers(func(vu int) (Requester, error) {
+			r := &countingRequesterImpl{}
+			if vu == 1 {
+				r.initErr = errors.New("init")
+			}
+			made = append(made, r)
+			return r, nil
+		}, &setting.Setting{MaxConcurrent: 3, RunDuration: 1 * time.Second}, 0)
+		require.NoError(t, err)
+
+		assert.Error(t, ot.Start(context.Background()))
+		require.Len(t, made, 3)
+		assert.Equal(t, 1, made[0].terminates, "initialized virtual users must be terminated")
+		assert.Equal(t, 0, made[1].terminates)
+		assert.Equal(t, 0, made[2].inits)
+	})
+}